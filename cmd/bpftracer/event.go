@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// eventSize is the size of struct event's fields in bpftracer.bpf.c: an
+// 8-byte timestamp, three 4-byte fields, and a 16-byte comm. The C struct
+// itself is padded to 40 bytes, so samples carry 4 trailing bytes that
+// decodeEvent ignores.
+const eventSize = 8 + 4 + 4 + 4 + 16
+
+// Event is a single decoded sched_switch sample.
+type Event struct {
+	// TimestampNs is the CLOCK_MONOTONIC timestamp the BPF program recorded
+	// the switch at.
+	TimestampNs uint64
+	// CPU is the processor the switch happened on.
+	CPU uint32
+	// PID is the kernel thread id of the task that started running.
+	PID uint32
+	// TGID is the thread group id (what userspace calls the PID) of the
+	// task that started running.
+	TGID uint32
+	// Comm is the task's command name, read from its task_struct.
+	Comm string
+}
+
+// decodeEvent parses a single struct event (see bpftracer.bpf.c) out of raw.
+func decodeEvent(raw []byte) (Event, error) {
+	if len(raw) < eventSize {
+		return Event{}, fmt.Errorf("sample too short: got %d bytes, want at least %d", len(raw), eventSize)
+	}
+
+	return Event{
+		TimestampNs: binary.LittleEndian.Uint64(raw[0:8]),
+		CPU:         binary.LittleEndian.Uint32(raw[8:12]),
+		PID:         binary.LittleEndian.Uint32(raw[12:16]),
+		TGID:        binary.LittleEndian.Uint32(raw[16:20]),
+		Comm:        string(bytes.TrimRight(raw[20:36], "\x00")),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	raw := make([]byte, eventSize)
+	binary.LittleEndian.PutUint64(raw[0:8], 123456789)
+	binary.LittleEndian.PutUint32(raw[8:12], 2)
+	binary.LittleEndian.PutUint32(raw[12:16], 4242)
+	binary.LittleEndian.PutUint32(raw[16:20], 4200)
+	copy(raw[20:36], "myprocess")
+
+	event, err := decodeEvent(raw)
+	if err != nil {
+		t.Fatalf("decodeEvent: %v", err)
+	}
+
+	want := Event{
+		TimestampNs: 123456789,
+		CPU:         2,
+		PID:         4242,
+		TGID:        4200,
+		Comm:        "myprocess",
+	}
+	if event != want {
+		t.Errorf("expected %+v, got %+v", want, event)
+	}
+}
+
+func TestDecodeEventTooShort(t *testing.T) {
+	if _, err := decodeEvent(make([]byte, eventSize-1)); err == nil {
+		t.Error("expected error for a short sample, got nil")
+	}
+}
@@ -1,57 +1,31 @@
 package main
 
 import (
-	"fmt"
-	"os"
-	"os/exec"
-	// "os/signal"
-	// "syscall"
-	"time"
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/cilium/ebpf/rlimit"
 )
 
 func main() {
-	// Path to bpftrace script
-	scriptPath := "./unvariance_bpftracer.bt"
-
-	// Command to run the bpftrace script
-	cmd := exec.Command("sudo", "bpftrace", scriptPath)
-
-	// Set up stdout and stderr
-	cmd.Stdout = os.Stdout
-	cmd. Stderr = os.Stderr
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	if err := cmd.Start(); err != nil {
-		fmt.Printf("Failed to start bpftrace: %v\n", err)
-		return
+	if err := rlimit.RemoveMemlock(); err != nil {
+		log.Fatalf("failed to remove memlock: %v", err)
 	}
 
-	// Set up signal handling to stop the command gracefully
-	// sig := make(chan os.Signal, 1)
-	// signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-
-	// Wait for the command to finish or for a signal to be received
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			fmt.Printf("bpftrace exited with error: %v\n", err)
-		}
-	}()
+	tracer, err := NewTracer()
+	if err != nil {
+		log.Fatalf("failed to start tracer: %v", err)
+	}
+	defer tracer.Close()
 
-	// Wait for a set time
-	fmt.Println("Running bpftrace for set time...")
-	time.Sleep(1000 * time.Millisecond)
+	tracer.Start(ctx)
 
-	// Kill the bpftrace process
-	fmt.Println("Stopping bpftrace...")
-	if err := cmd.Process.Kill(); err != nil {
-		fmt.Printf("Failed to kill bpftrace: %v\n", err)
+	for event := range tracer.Events() {
+		log.Printf("cpu=%d comm=%q pid=%d tgid=%d ts=%d", event.CPU, event.Comm, event.PID, event.TGID, event.TimestampNs)
 	}
-
-	// // Wait for a signal
-	// <-sig
-	// fmt.Println("Received signal, stopping bpftrace...")
-// 
-	// // Kill the bpftrace process
-	// if err := cmd.Process.Kill(); err != nil {
-		// fmt.Printf("Failed to kill bpftrace: %v\n", err)
-	// }
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/unvariance/collector/pkg/perf"
+	"github.com/unvariance/collector/pkg/perf_ebpf"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang bpftracer bpftracer.bpf.c -- -I/usr/include/x86_64-linux-gnu
+
+// Tracer attaches the sched_switch BPF program and decodes its ring buffer
+// output into a channel of Events. It replaces the old exec'd bpftrace
+// subprocess: the program is loaded and attached natively via cilium/ebpf,
+// and samples flow through the same perf_ebpf.EventReader pipeline the rest
+// of the collector uses instead of being printed to stdout.
+type Tracer struct {
+	objs   bpftracerObjects
+	link   link.Link
+	reader perf_ebpf.EventReader
+
+	events chan Event
+	done   chan struct{}
+
+	started         atomic.Bool
+	closeReaderOnce sync.Once
+}
+
+// NewTracer loads and attaches the tracer's BPF program and prepares its
+// ring buffer reader, without starting to decode events yet - call Start
+// for that.
+func NewTracer() (*Tracer, error) {
+	objs := bpftracerObjects{}
+	if err := loadBpftracerObjects(&objs, nil); err != nil {
+		return nil, fmt.Errorf("loading bpftracer objects: %w", err)
+	}
+
+	tp, err := link.AttachTracing(link.TracingOptions{
+		Program:    objs.TraceSchedSwitch,
+		AttachType: ebpf.AttachTraceRawTp,
+	})
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching sched_switch tp_btf: %w", err)
+	}
+
+	reader, err := perf_ebpf.NewRingBufMapReader(objs.Events, perf_ebpf.RingBufOptions{})
+	if err != nil {
+		tp.Close()
+		objs.Close()
+		return nil, fmt.Errorf("creating ring buffer reader: %w", err)
+	}
+
+	return &Tracer{
+		objs:   objs,
+		link:   tp,
+		reader: reader,
+		events: make(chan Event, 4096),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start decodes events in a background goroutine until ctx is cancelled or
+// Close is called, sending each one to the channel Events returns.
+func (t *Tracer) Start(ctx context.Context) {
+	t.started.Store(true)
+	go func() {
+		<-ctx.Done()
+		t.closeReaderOnce.Do(func() { t.reader.Close() })
+	}()
+	go t.run()
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	defer close(t.events)
+
+	var rec perf.Record
+	for {
+		if err := t.reader.Read(&rec); err != nil {
+			// The reader was closed, either by Start's context watcher or
+			// by Close; either way, there's nothing left to decode.
+			return
+		}
+		event, err := decodeEvent(rec.RawSample)
+		if err != nil {
+			continue
+		}
+		t.events <- event
+	}
+}
+
+// Events returns the channel Start sends decoded events to. It's closed
+// once the tracer stops, so callers can range over it.
+func (t *Tracer) Events() <-chan Event {
+	return t.events
+}
+
+// Close detaches the BPF program and releases every resource the tracer
+// holds, waiting for the decode goroutine started by Start (if any) to
+// finish first. It's safe to call more than once.
+func (t *Tracer) Close() error {
+	t.closeReaderOnce.Do(func() {
+		if t.reader != nil {
+			t.reader.Close()
+		}
+	})
+	if t.started.Load() {
+		<-t.done
+	}
+
+	if t.link != nil {
+		t.link.Close()
+	}
+	return t.objs.Close()
+}
@@ -0,0 +1,24 @@
+// Command checker verifies that the host supports resctrl-based cache
+// occupancy monitoring (CMT), mounting resctrl if necessary, and reports the
+// capabilities it discovers.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/unvariance/collector/pkg/checker"
+)
+
+func main() {
+	fmt.Println("Checking resctrl support")
+
+	mountPoint, caps, err := checker.CheckResctrlSupport(checker.ResctrlOptions{})
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("resctrl mounted at %s, CMT=%v MBM=%v num_rmids=%d\n",
+		mountPoint, caps.CMT, caps.MBM, caps.NumRMIDs)
+}
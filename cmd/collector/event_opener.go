@@ -16,10 +16,42 @@ type EventOpener struct {
 	mu       sync.Mutex
 	array    *ebpf.Map
 	eventFDs []int
+	cgroupFD int // -1 unless opened by NewCgroupEventOpener
 }
 
 // NewEventOpener creates perf events for each CPU with the given attributes
 func NewEventOpener(array *ebpf.Map, attr unix.PerfEventAttr) (*EventOpener, error) {
+	return newEventOpener(array, attr, -1, -1, 0)
+}
+
+// NewCgroupEventOpener creates perf events for each CPU scoped to the cgroup
+// v2 directory at cgroupPath, so the resulting counters only cover tasks in
+// that cgroup (and, since PerfBitInherit is set on attr, its descendants) -
+// e.g. attributing LLC/mem-bandwidth counters to a single Kubernetes pod
+// rather than the whole host. The cgroup directory's FD is kept open for the
+// lifetime of the opener and closed alongside the event FDs in Close.
+func NewCgroupEventOpener(array *ebpf.Map, attr unix.PerfEventAttr, cgroupPath string) (*EventOpener, error) {
+	cgroupFD, err := unix.Open(cgroupPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup %q: %v", cgroupPath, err)
+	}
+
+	attr.Bits |= unix.PerfBitInherit
+
+	eo, err := newEventOpener(array, attr, cgroupFD, cgroupFD, unix.PERF_FLAG_PID_CGROUP)
+	if err != nil {
+		unix.Close(cgroupFD)
+		return nil, err
+	}
+	return eo, nil
+}
+
+// newEventOpener creates perf events for each CPU with the given attributes
+// and pid/flags, storing each CPU's event FD in array. pid is the second
+// argument to PerfEventOpen (-1 for "all tasks", or a cgroup FD alongside
+// unix.PERF_FLAG_PID_CGROUP); cgroupFD is kept open by the returned
+// EventOpener and closed alongside the event FDs, or -1 if there is none.
+func newEventOpener(array *ebpf.Map, attr unix.PerfEventAttr, pid, cgroupFD int, flags int) (*EventOpener, error) {
 	nCPU := int(array.MaxEntries())
 	eventFDs := make([]int, 0, nCPU)
 
@@ -31,7 +63,7 @@ func NewEventOpener(array *ebpf.Map, attr unix.PerfEventAttr) (*EventOpener, err
 
 	// Create perf events for each CPU
 	for cpu := 0; cpu < nCPU; cpu++ {
-		fd, err := unix.PerfEventOpen(&attr, -1, cpu, -1, 0)
+		fd, err := unix.PerfEventOpen(&attr, pid, cpu, -1, flags)
 		if err != nil {
 			// Clean up already opened FDs
 			for _, fd := range eventFDs {
@@ -55,6 +87,7 @@ func NewEventOpener(array *ebpf.Map, attr unix.PerfEventAttr) (*EventOpener, err
 	eo := &EventOpener{
 		array:    array,
 		eventFDs: eventFDs,
+		cgroupFD: cgroupFD,
 	}
 	runtime.SetFinalizer(eo, (*EventOpener).Close)
 	return eo, nil
@@ -76,6 +109,13 @@ func (eo *EventOpener) Close() error {
 		}
 	}
 
+	if eo.cgroupFD >= 0 {
+		if err := unix.Close(eo.cgroupFD); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		eo.cgroupFD = -1
+	}
+
 	if err := eo.array.Close(); err != nil && firstErr == nil {
 		firstErr = err
 	}
@@ -7,16 +7,29 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"runtime"
 	"time"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/rlimit"
+	"github.com/unvariance/collector/pkg/cgroup"
+	perfctrl "github.com/unvariance/collector/pkg/perf"
+	"github.com/unvariance/collector/pkg/runtimetune"
 )
 
+// watermarkStableWindowsForIncrease is how many consecutive loss-free, low-fill
+// one-second windows are required before the watermark controller grows the
+// watermark by one page.
+const watermarkStableWindowsForIncrease = 4
+
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang taskCounter task_counter.c -- -I/usr/include/x86_64-linux-gnu
 
 func main() {
+	// Apply GOMAXPROCS/GOMEMLIMIT tuning based on the cgroup's CPU and memory limits
+	// before doing anything else, so the rest of startup runs under the final settings.
+	runtimetune.Tune()
+
 	// Allow the current process to lock memory for eBPF resources
 	if err := rlimit.RemoveMemlock(); err != nil {
 		log.Fatal(err)
@@ -36,8 +49,25 @@ func main() {
 	}
 	defer tp.Close()
 
-	// Create a ReaderOptions with a large Watermark
-	perCPUBufferSize := 16 * os.Getpagesize()
+	// Resolve cgroup ids captured in each sample to the pod/container that emitted it.
+	resolver := cgroup.NewResolver("/sys/fs/cgroup")
+	stopEviction := make(chan struct{})
+	defer close(stopEviction)
+	startCgroupEviction(resolver, stopEviction)
+
+	// Create a ReaderOptions with a large Watermark. The per-CPU buffer defaults to 16
+	// pages, scaled down in proportion when the cgroup's cpuset/affinity allows fewer
+	// CPUs than runtime.NumCPU reports, so the total buffer memory shrinks with the
+	// number of CPUs the process may use.
+	perCPUPages := 16
+	if cpus, err := runtimetune.EffectiveCPUs(); err == nil {
+		if numCPU := runtime.NumCPU(); numCPU > 0 && len(cpus) < numCPU {
+			if scaled := perCPUPages * len(cpus) / numCPU; scaled >= 1 {
+				perCPUPages = scaled
+			}
+		}
+	}
+	perCPUBufferSize := perCPUPages * os.Getpagesize()
 	opts := perf.ReaderOptions{
 		Watermark: perCPUBufferSize / 2,
 	}
@@ -47,7 +77,16 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer rd.Close()
+	defer func() {
+		rd.Close()
+	}()
+
+	// watermarkCtrl auto-tunes the wakeup watermark from the observed lost-sample
+	// rate, halving it on loss and growing it back by a page once things have been
+	// quiet for a while, instead of leaving it pinned at its initial value forever.
+	watermarkCtrl := perfctrl.NewWatermarkController(
+		uint32(os.Getpagesize()), uint32(perCPUBufferSize), uint32(opts.Watermark), watermarkStableWindowsForIncrease,
+	)
 
 	// Catch CTRL+C
 	stopper := make(chan os.Signal, 1)
@@ -63,6 +102,7 @@ func main() {
 
 	// Counter to maintain in userspace
 	var totalEvents uint64 = 0
+	var windowLost, windowAccepted uint64
 
 	for {
 		select {
@@ -86,11 +126,35 @@ func main() {
 					log.Fatal(err)
 				}
 				log.Printf("Event count: userspace %d, eBPF %d\n", totalEvents, count)
+
+				// Feed this window's lost/accepted counts into the watermark controller.
+				// The upstream perf.Reader doesn't expose the ring's current fill level,
+				// so we can't evaluate the low-fill-percentage leg of the AIMD rule
+				// directly; a loss-free window is treated as low-fill for the purposes
+				// of the additive-increase leg.
+				watermarkCtrl.Observe(windowLost, windowAccepted, uint32(perCPUBufferSize))
+				windowLost, windowAccepted = 0, 0
+
+				watermarkBytes.Set(float64(watermarkCtrl.Watermark()))
+				lostAcceptedRatio.Set(watermarkCtrl.LostAcceptedRatio())
+
+				if newWatermark := int(watermarkCtrl.Watermark()); newWatermark != opts.Watermark {
+					newOpts := perf.ReaderOptions{Watermark: newWatermark}
+					newRd, err := perf.NewReaderWithOptions(objs.Events, perCPUBufferSize, newOpts)
+					if err != nil {
+						log.Printf("Reopening perf reader with watermark %d: %s", newWatermark, err)
+					} else {
+						rd.Close()
+						rd = newRd
+						opts = newOpts
+						rd.SetDeadline(nextDeadline)
+					}
+				}
 			}
 
 			record, err := rd.Read()
 			if err != nil {
-				if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, perf.ErrFlushed) {					
+				if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, perf.ErrFlushed) {
 					break // make for loop check the select statement and set the deadline
 				} else if errors.Is(err, perf.ErrClosed) {
 					return
@@ -101,6 +165,7 @@ func main() {
 
 			if record.LostSamples != 0 {
 				log.Printf("Lost %d samples", record.LostSamples)
+				windowLost += record.LostSamples
 				continue
 			}
 
@@ -111,7 +176,9 @@ func main() {
 				continue
 			}
 
+			recordSample(resolver, event.CgroupID)
 			totalEvents++
+			windowAccepted++
 		}
 	}
 }
\ No newline at end of file
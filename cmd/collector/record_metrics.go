@@ -1,18 +1,74 @@
 package main
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unvariance/collector/pkg/cgroup"
 )
 
+// cgroupEvictionInterval controls how often stale cgroups are dropped from the
+// resolver and from memoryEventsTotal's label set, bounding metric cardinality.
+const cgroupEvictionInterval = 30 * time.Second
+
+// cgroupEvictionMaxAge is how long a cgroup can go unobserved before it's considered gone.
+const cgroupEvictionMaxAge = 2 * time.Minute
+
+var memoryEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "perfpod",
+	Subsystem: "memory_collector",
+	Name:      "memory_events_total",
+	Help:      "Number of memory-activity samples attributed to a container.",
+}, []string{"cgroup", "pod", "container", "namespace"})
+
+var watermarkBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "perfpod",
+	Subsystem: "memory_collector",
+	Name:      "perf_watermark_bytes",
+	Help:      "Current auto-tuned perf ring wakeup watermark, in bytes.",
+})
+
+var lostAcceptedRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "perfpod",
+	Subsystem: "memory_collector",
+	Name:      "perf_lost_accepted_ratio",
+	Help:      "Running ratio of lost to accepted perf samples.",
+})
 
 func recordMetrics() {
 	upMetric := prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: 	"perfpod",
-			Subsystem: 	"memory_collector",
-			Name: 		"up_metric",
-			Help: 		"Test metric to confirm skeleton application functionality.",
-		})
-		prometheus.MustRegister(upMetric)
-	
-		upMetric.Set(1)
+		Namespace: "perfpod",
+		Subsystem: "memory_collector",
+		Name:      "up_metric",
+		Help:      "Test metric to confirm skeleton application functionality.",
+	})
+	prometheus.MustRegister(upMetric)
+	prometheus.MustRegister(memoryEventsTotal)
+	prometheus.MustRegister(watermarkBytes)
+	prometheus.MustRegister(lostAcceptedRatio)
+
+	upMetric.Set(1)
+}
+
+// recordSample attributes one sample to the cgroup it was taken in, resolving the
+// cgroup id to a pod/container via resolver and incrementing the labeled counter.
+func recordSample(resolver *cgroup.Resolver, cgroupID uint64) {
+	info, ok := resolver.Resolve(cgroupID)
+	if !ok {
+		// The cgroup has already been removed; still count it, unlabeled, rather
+		// than silently dropping the sample.
+		memoryEventsTotal.WithLabelValues("", "", "", "").Inc()
+		return
+	}
+
+	memoryEventsTotal.WithLabelValues(info.Path, info.PodUID, info.Container, info.Namespace).Inc()
+}
+
+// startCgroupEviction runs resolver's eviction loop and clears the corresponding label
+// set from memoryEventsTotal whenever a cgroup ages out, so cardinality stays bounded.
+func startCgroupEviction(resolver *cgroup.Resolver, stop <-chan struct{}) {
+	resolver.OnEvict(func(_ uint64, info cgroup.Info) {
+		memoryEventsTotal.DeleteLabelValues(info.Path, info.PodUID, info.Container, info.Namespace)
+	})
+	resolver.Run(cgroupEvictionInterval, cgroupEvictionMaxAge, stop)
 }
@@ -3,11 +3,12 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"flag"
 	"log"
 	"os"
 	"runtime"
 
-	"github.com/elastic/go-perf"
+	"github.com/unvariance/collector/pkg/measure"
 )
 
 func init() {
@@ -21,47 +22,72 @@ func init() {
 }
 
 func main() {
+	verify := flag.Bool("verify", false, "cross-check GoPerf, perf stat, and the raw counter backend against each other instead of just reporting GoPerf's numbers")
+	epsilon := flag.Float64("epsilon", 0.15, "relative tolerance used by -verify")
+	flag.Parse()
+
 	pid := os.Getpid()
 	log.Printf("Current PID: %d\n", pid)
 
-	perfCmd := NewPerfCmd(pid)
-	if err := perfCmd.Start(); err != nil {
-		log.Fatalf("Failed to execute perf cmd: %v\n", err)
+	if !*verify {
+		runGoPerf()
+		return
 	}
+	runVerify(pid, *epsilon)
+}
 
-	log.Printf("Started perf cmd\n")
-	g := perf.Group{
-		CountFormat: perf.CountFormat{
-			Running: true,
-		},
+func runGoPerf() {
+	goperf := measure.NewGoPerfMeasurer()
+	if err := goperf.Start(); err != nil {
+		log.Fatalf("Failed to start GoPerf: %v\n", err)
 	}
-	g.Add(perf.Instructions, perf.CPUCycles)
 
-	p, err := g.Open(perf.CallingThread, perf.AnyCPU)
+	workloadOutput := heavyWorkload()
+
+	out, err := goperf.End()
 	if err != nil {
-		log.Fatalf("Failed to open perf events: %s\n", err)
+		log.Fatalf("Failed to end GoPerf: %v\n", err)
 	}
 
-	var workloadOutput string
-	gc, err := p.MeasureGroup(func() {
-		workloadOutput = heavyWorkload()
-	})
+	log.Printf("Output is %s\n", workloadOutput)
+	logOutput("GoPerf", out)
+}
 
-	if err != nil {
-		log.Fatalf("Failed to measure perf group: %s\n", err)
+func runVerify(pid int, epsilon float64) {
+	goperf := measure.NewGoPerfMeasurer()
+	perfCmd := measure.NewPerfCmdMeasurer(pid)
+	counter := measure.NewCounterMeasurer(measure.CallingThread, measure.AnyCPU)
+	defer counter.Close()
+
+	multi := measure.NewMultiMeasurer(epsilon, goperf, perfCmd, counter)
+	if err := multi.Start(); err != nil {
+		log.Fatalf("Failed to start measurers: %v\n", err)
+	}
+
+	workloadOutput := heavyWorkload()
+
+	outputs, err := multi.Verify()
+
+	log.Printf("Output is %s\n", workloadOutput)
+	labels := []string{"GoPerf", "PerfCmd", "Counter"}
+	for i, out := range outputs {
+		if out == nil {
+			continue
+		}
+		logOutput(labels[i], out)
 	}
 
-	p.Close()
-	perfOutput, err := perfCmd.End()
 	if err != nil {
-		log.Fatalf("Failed to end perf cmd: %v\n", err)
+		log.Fatalf("Verification failed: %v\n", err)
 	}
+	log.Printf("All measurers agreed within epsilon=%v\n", epsilon)
+}
 
-	cycles, instrs := gc.Values[1].Value, gc.Values[0].Value
-	log.Printf("Output is %s\n", workloadOutput)
-	log.Printf("Ran for %dms\n", gc.Running.Milliseconds())
-	log.Printf("GoPerf Cycles: %d, GoPerf Instrs: %d, GoPerf CPI: %f\n", cycles, instrs, float64(cycles)/float64(instrs))
-	log.Printf("PerfCmd Cycles: %d, PerfCmd Instrs: %d, PerfCmd CPI: %f\n", int64(perfOutput.Cycles), int64(perfOutput.Instrs), perfOutput.Cycles/perfOutput.Instrs)
+func logOutput(label string, out *measure.Output) {
+	log.Printf("%s Cycles: %v, %s Instrs: %v, %s CPI: %f\n",
+		label, out.Values[measure.EventCPUCycles],
+		label, out.Values[measure.EventInstructions],
+		label, out.CPI())
 }
 
 func heavyWorkload() string {
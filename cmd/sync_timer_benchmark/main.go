@@ -8,6 +8,7 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"hash/crc32"
 	"log"
 	"math"
 	"math/bits"
@@ -18,15 +19,30 @@ import (
 
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
+	"github.com/unvariance/collector/pkg/perf"
 	"github.com/unvariance/collector/pkg/perf_ebpf"
 	"github.com/unvariance/collector/pkg/sync_timer"
 )
 
+// benchmarkMsgSize is the size in bytes of the bench_event payload
+// (tick_number, delta, timestamp: three uint64s).
+const benchmarkMsgSize = 24
+
+// benchmarkTrailerSize is the size of the CRC32C trailer the BPF producer
+// appends after the payload when checksums are enabled (see the -checksum flag).
+const benchmarkTrailerSize = 4
+
+// crcTable is the CRC32C (Castagnoli) polynomial table used to verify the
+// BPF producer's per-record trailer, computed over {tick_number, delta,
+// timestamp} exactly as the producer does before appending it to the record.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
 func main() {
 	// Parse command line flags
 	duration := flag.Duration("duration", 10*time.Second, "Duration to run the benchmark")
 	csvFile := flag.String("csv", "results.csv", "Output CSV file for benchmark results")
 	experimentName := flag.String("experiment", "", "Name of the experiment (e.g., baseline, cpu_stress)")
+	checksum := flag.Bool("checksum", true, "verify the CRC32C trailer the BPF producer appends to each event")
 	flag.Parse()
 
 	// Allow the current process to lock memory for eBPF resources
@@ -119,6 +135,8 @@ func main() {
 		"mean_delay",
 		"stddev",
 		"samples",
+		"corrupt_events",
+		"lost_events",
 		"experiment",
 	}); err != nil {
 		fmt.Printf("Error writing CSV header: %v\n", err)
@@ -151,6 +169,15 @@ func main() {
 		sumSquaredDelta uint64
 	})
 
+	var corruptEvents uint64
+	var lostEvents uint64
+
+	recordSize := benchmarkMsgSize
+	if *checksum {
+		recordSize += benchmarkTrailerSize
+	}
+	eventData := make([]byte, recordSize)
+
 	for !reader.Empty() {
 		// Get current ring
 		ring, _, err := reader.CurrentRing()
@@ -159,16 +186,45 @@ func main() {
 			break
 		}
 
+		// Lost-sample notifications arrive as their own record, interleaved
+		// with bench_event samples; they don't carry a bench_event payload.
+		if ring.PeekType() == perf.PERF_RECORD_LOST {
+			lost, err := rd.HandleLostRecord(ring)
+			if err != nil {
+				fmt.Printf("Error reading lost record: %v\n", err)
+				break
+			}
+			lostEvents += lost
+			if err := reader.Pop(); err != nil {
+				fmt.Printf("Error consuming lost record: %v\n", err)
+				break
+			}
+			continue
+		}
+
 		// Read event data
-		eventData := make([]byte, 24) // Size of bench_event struct
 		if err := ring.PeekCopy(eventData, 4); err != nil {
 			fmt.Printf("Error reading event: %v\n", err)
 			break
 		}
+		payload := eventData[:benchmarkMsgSize]
+
+		if *checksum {
+			trailer := eventData[benchmarkMsgSize:]
+			want := binary.LittleEndian.Uint32(trailer)
+			if got := crc32.Checksum(payload, crcTable); got != want {
+				corruptEvents++
+				if err := reader.Pop(); err != nil {
+					fmt.Printf("Error consuming corrupt event: %v\n", err)
+					break
+				}
+				continue
+			}
+		}
 
 		// Parse event
 		var event bpfBenchmarkMsg
-		if err := binary.Read(bytes.NewReader(eventData), binary.LittleEndian, &event); err != nil {
+		if err := binary.Read(bytes.NewReader(payload), binary.LittleEndian, &event); err != nil {
 			log.Printf("Failed to parse perf event: %s", err)
 			break
 		}
@@ -207,6 +263,8 @@ func main() {
 			fmt.Sprintf("%.2f", meanDelay),
 			fmt.Sprintf("%.2f", stddev),
 			fmt.Sprintf("%d", stats.count),
+			fmt.Sprintf("%d", corruptEvents),
+			fmt.Sprintf("%d", lostEvents),
 			*experimentName,
 		}); err != nil {
 			fmt.Printf("Error writing CSV row: %v\n", err)
@@ -239,4 +297,6 @@ func main() {
 		fmt.Printf("Minimum Delta: %d ns\n", minDelta)
 		fmt.Printf("Maximum Delta: %d ns\n", maxDelta)
 	}
+	fmt.Printf("Corrupt Events: %d\n", corruptEvents)
+	fmt.Printf("Lost Events: %d\n", lostEvents)
 }
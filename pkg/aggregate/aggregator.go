@@ -2,6 +2,7 @@ package aggregate
 
 import (
 	"fmt"
+	"math/bits"
 )
 
 // Measurement represents a single measurement from a perf event
@@ -35,12 +36,33 @@ type Config struct {
 	SlotLength uint64 // nanoseconds
 	WindowSize uint   // number of consecutive slots
 	SlotOffset uint64 // nanoseconds, modulo SlotLength
+
+	// WAL, if non-nil, durably logs every completed TimeSlot to disk before it's
+	// handed back to the caller from AdvanceWindow or Reset.
+	WAL *WALConfig
+
+	// Thresholds are evaluated against each RMID's just-closed slot and its
+	// running high-water mark whenever a slot completes; see SetAlertSink.
+	Thresholds []ThresholdRule
+
+	// Sinks receive every completed TimeSlot in addition to it being returned
+	// from AdvanceWindow/Reset, e.g. to persist metrics to CSV or Postgres.
+	Sinks []Sink
 }
 
 // Aggregator manages the sliding window of time slots and measurement aggregation
 type Aggregator struct {
 	config    Config
 	timeSlots []*TimeSlot
+
+	wal    *WALWriter
+	walErr error
+
+	highWater map[uint32]*rmidHighWater
+	alertSink func(rmid uint32, event ThresholdEvent)
+
+	sinks   []Sink
+	sinkErr error
 }
 
 // NewAggregator creates a new Aggregator with the given configuration
@@ -55,10 +77,57 @@ func NewAggregator(config Config) (*Aggregator, error) {
 		return nil, fmt.Errorf("slot offset must be less than slot length")
 	}
 
-	return &Aggregator{
+	a := &Aggregator{
 		config:    config,
 		timeSlots: make([]*TimeSlot, 0, config.WindowSize),
-	}, nil
+		highWater: make(map[uint32]*rmidHighWater),
+		sinks:     config.Sinks,
+	}
+
+	if config.WAL != nil {
+		wal, err := NewWALWriter(*config.WAL)
+		if err != nil {
+			return nil, fmt.Errorf("opening aggregator WAL: %w", err)
+		}
+		a.wal = wal
+	}
+
+	return a, nil
+}
+
+// WALError returns the first error encountered writing a completed TimeSlot to
+// the WAL, if any. A WAL write failure doesn't interrupt aggregation: slots
+// are still returned to the caller normally, so this must be polled separately.
+func (a *Aggregator) WALError() error {
+	return a.walErr
+}
+
+// Close closes the aggregator's WAL and every registered sink, if any. It
+// returns the first error encountered but still attempts to close the rest.
+func (a *Aggregator) Close() error {
+	var firstErr error
+	if a.wal != nil {
+		if err := a.wal.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, sink := range a.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeWAL persists completed slots to the WAL, if configured, recording the
+// first failure in walErr rather than interrupting the caller's hot path.
+func (a *Aggregator) writeWAL(slots []*TimeSlot) {
+	if a.wal == nil || len(slots) == 0 {
+		return
+	}
+	if err := a.wal.WriteSlots(slots); err != nil && a.walErr == nil {
+		a.walErr = err
+	}
 }
 
 // getSlotStartTime returns the start time of the slot that would contain the given timestamp
@@ -125,6 +194,11 @@ func (a *Aggregator) AdvanceWindow(timestamp uint64, duration uint64) []*TimeSlo
 		a.timeSlots[i] = a.createTimeSlot(newEndSlotStart - uint64(int(windowSize)-1-i)*a.config.SlotLength)
 	}
 
+	for _, slot := range completedSlots {
+		a.evaluateThresholds(slot)
+	}
+	a.writeWAL(completedSlots)
+	a.writeSinks(completedSlots)
 	return completedSlots
 }
 
@@ -136,6 +210,17 @@ func safeSubtract(a, b uint64) int64 {
 	return int64(a) - int64(b)
 }
 
+// mulDivExact computes floor(a*b/c) using 128-bit intermediate arithmetic, so
+// it neither overflows for large a/b nor loses precision the way a
+// float64-based a*(b/c) would. Callers must guarantee b <= c: that keeps the
+// quotient no larger than a, which is what lets the final Div64 fit in a
+// uint64 without tripping its divide-overflow panic.
+func mulDivExact(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	q, _ := bits.Div64(hi, lo, c)
+	return q
+}
+
 // UpdateMeasurement updates aggregations with a new measurement
 func (a *Aggregator) UpdateMeasurement(m *Measurement) error {
 	// Advance window first to ensure we have the correct slots
@@ -188,10 +273,9 @@ func (a *Aggregator) UpdateMeasurement(m *Measurement) error {
 			instructions = remainingInstructions
 			llcMisses = remainingLLCMisses
 		} else {
-			proportion := float64(overlapDuration) / float64(remainingDuration)
-			cycles = uint64(float64(remainingCycles) * proportion)
-			instructions = uint64(float64(remainingInstructions) * proportion)
-			llcMisses = uint64(float64(remainingLLCMisses) * proportion)
+			cycles = mulDivExact(remainingCycles, overlapDuration, remainingDuration)
+			instructions = mulDivExact(remainingInstructions, overlapDuration, remainingDuration)
+			llcMisses = mulDivExact(remainingLLCMisses, overlapDuration, remainingDuration)
 		}
 
 		// Update or create aggregation for this RMID
@@ -209,6 +293,8 @@ func (a *Aggregator) UpdateMeasurement(m *Measurement) error {
 		agg.LLCMisses += llcMisses
 		agg.Duration += overlapDuration
 
+		a.updateHighWater(m.RMID, agg)
+
 		// Update remaining values
 		remainingDuration -= overlapDuration
 		remainingCycles -= cycles
@@ -224,10 +310,17 @@ func (a *Aggregator) UpdateMeasurement(m *Measurement) error {
 	return nil
 }
 
-// Reset returns all existing time slots and resets the aggregator
+// Reset returns all existing time slots and resets the aggregator, including
+// clearing all per-RMID high-water state so a fresh window starts from zero.
 func (a *Aggregator) Reset() []*TimeSlot {
 	slots := a.timeSlots
 	a.timeSlots = make([]*TimeSlot, 0, a.config.WindowSize)
+	for _, slot := range slots {
+		a.evaluateThresholds(slot)
+	}
+	a.highWater = make(map[uint32]*rmidHighWater)
+	a.writeWAL(slots)
+	a.writeSinks(slots)
 	return slots
 }
 
@@ -1,6 +1,7 @@
 package aggregate
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -531,3 +532,85 @@ func TestSafeSubtract(t *testing.T) {
 		})
 	}
 }
+
+func TestMulDivExact(t *testing.T) {
+	tests := []struct {
+		a, b, c  uint64
+		expected uint64
+	}{
+		{a: 1000, b: 500_000, c: 1_000_000, expected: 500},
+		{a: 0, b: 1, c: 1, expected: 0},
+		{a: ^uint64(0), b: 1, c: 1, expected: ^uint64(0)},
+		{a: ^uint64(0), b: ^uint64(0) - 1, c: ^uint64(0), expected: ^uint64(0) - 1},
+	}
+
+	for _, tt := range tests {
+		if got := mulDivExact(tt.a, tt.b, tt.c); got != tt.expected {
+			t.Errorf("mulDivExact(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.c, got, tt.expected)
+		}
+	}
+}
+
+// TestAggregator_UpdateMeasurement_ConservesTotals is a property-based test:
+// it generates random measurements straddling arbitrary slot boundaries and
+// asserts that summing the per-slot aggregations a single UpdateMeasurement
+// call produced exactly equals that measurement's Cycles/Instructions/
+// LLCMisses, with no rounding loss from the proportional distribution.
+func TestAggregator_UpdateMeasurement_ConservesTotals(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	for iter := 0; iter < 1000; iter++ {
+		config := Config{
+			SlotLength: 1 + uint64(rng.Intn(1_000_000)),
+			WindowSize: uint(1 + rng.Intn(8)),
+		}
+		config.SlotOffset = uint64(rng.Int63n(int64(config.SlotLength)))
+
+		agg, err := NewAggregator(config)
+		if err != nil {
+			t.Fatalf("NewAggregator() error = %v", err)
+		}
+
+		// Keep the measurement's span within the window: UpdateMeasurement
+		// only distributes overlap across the slots AdvanceWindow created for
+		// this timestamp, so a duration reaching further back than the
+		// window's oldest slot would have nowhere to put the remainder. The
+		// timestamp can land as little as 1ns into the slot containing
+		// measurementEndTime, so the safe bound is (WindowSize-1) full slots
+		// plus that 1ns, not WindowSize full slots.
+		maxDuration := uint64(config.WindowSize-1)*config.SlotLength + 1
+		duration := uint64(1 + rng.Int63n(int64(maxDuration)))
+		timestamp := duration + uint64(rng.Intn(1_000_000))
+		m := &Measurement{
+			RMID:         1,
+			Cycles:       rng.Uint64(),
+			Instructions: rng.Uint64(),
+			LLCMisses:    rng.Uint64(),
+			Timestamp:    timestamp,
+			Duration:     duration,
+		}
+
+		if err := agg.UpdateMeasurement(m); err != nil {
+			t.Fatalf("UpdateMeasurement() error = %v", err)
+		}
+
+		var cycles, instructions, llcMisses uint64
+		for _, slot := range agg.timeSlots {
+			if a, ok := slot.Aggregations[m.RMID]; ok {
+				cycles += a.Cycles
+				instructions += a.Instructions
+				llcMisses += a.LLCMisses
+			}
+		}
+
+		if cycles != m.Cycles {
+			t.Fatalf("iter %d: cycles = %d, want %d (config=%+v, m=%+v)", iter, cycles, m.Cycles, config, m)
+		}
+		if instructions != m.Instructions {
+			t.Fatalf("iter %d: instructions = %d, want %d (config=%+v, m=%+v)", iter, instructions, m.Instructions, config, m)
+		}
+		if llcMisses != m.LLCMisses {
+			t.Fatalf("iter %d: llcMisses = %d, want %d (config=%+v, m=%+v)", iter, llcMisses, m.LLCMisses, config, m)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// csvHeader is the column order written by CSVSink, one row per (slot, RMID).
+var csvHeader = []string{
+	"slot_start",
+	"slot_end",
+	"rmid",
+	"cycles",
+	"instructions",
+	"llc_misses",
+	"duration",
+}
+
+// CSVSink writes completed TimeSlots as CSV rows, one per RMID per slot, in
+// the column order given by csvHeader. Each Write call hands its rows to the
+// underlying csv.Writer at once rather than batching across calls; they reach
+// the destination when csv.Writer's buffer fills or on Flush/Close.
+type CSVSink struct {
+	mu          sync.Mutex
+	w           *csv.Writer
+	closer      io.Closer
+	wroteHeader bool
+}
+
+// NewCSVSink wraps w, writing a header before the first row.
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// NewCSVFileSink creates (or truncates) the file at path and returns a
+// CSVSink writing to it; Close closes the underlying file.
+func NewCSVFileSink(path string) (*CSVSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating CSV sink file: %w", err)
+	}
+	sink := NewCSVSink(f)
+	sink.closer = f
+	return sink, nil
+}
+
+// Write appends one CSV row per RMID aggregation across slots, sorting RMIDs
+// within each slot for deterministic output.
+func (s *CSVSink) Write(_ context.Context, slots []*TimeSlot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.wroteHeader {
+		if err := s.w.Write(csvHeader); err != nil {
+			return fmt.Errorf("writing CSV header: %w", err)
+		}
+		s.wroteHeader = true
+	}
+
+	for _, slot := range slots {
+		rmids := make([]uint32, 0, len(slot.Aggregations))
+		for rmid := range slot.Aggregations {
+			rmids = append(rmids, rmid)
+		}
+		sort.Slice(rmids, func(i, j int) bool { return rmids[i] < rmids[j] })
+
+		for _, rmid := range rmids {
+			agg := slot.Aggregations[rmid]
+			row := []string{
+				fmt.Sprintf("%d", slot.StartTime),
+				fmt.Sprintf("%d", slot.EndTime),
+				fmt.Sprintf("%d", rmid),
+				fmt.Sprintf("%d", agg.Cycles),
+				fmt.Sprintf("%d", agg.Instructions),
+				fmt.Sprintf("%d", agg.LLCMisses),
+				fmt.Sprintf("%d", agg.Duration),
+			}
+			if err := s.w.Write(row); err != nil {
+				return fmt.Errorf("writing CSV row: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Flush flushes any CSV rows buffered by the underlying csv.Writer.
+func (s *CSVSink) Flush(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Close flushes and, if the sink owns its destination (NewCSVFileSink),
+// closes the underlying file.
+func (s *CSVSink) Close() error {
+	if err := s.Flush(context.Background()); err != nil {
+		return err
+	}
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
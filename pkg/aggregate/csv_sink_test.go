@@ -0,0 +1,79 @@
+package aggregate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCSVSinkWritesHeaderAndRows(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewCSVSink(&buf)
+
+	slot := sampleSlot(1_000_000)
+	if err := sink.Write(context.Background(), []*TimeSlot{slot}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := buf.String()
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 1+len(slot.Aggregations) {
+		t.Fatalf("expected %d lines (header + %d rows), got %d: %q", 1+len(slot.Aggregations), len(slot.Aggregations), len(lines), out)
+	}
+	if lines[0] != strings.Join(csvHeader, ",") {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+}
+
+func TestCSVSinkAccumulatesAcrossWrites(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewCSVSink(&buf)
+
+	if err := sink.Write(context.Background(), []*TimeSlot{sampleSlot(0)}); err != nil {
+		t.Fatalf("Write 1: %v", err)
+	}
+	if err := sink.Write(context.Background(), []*TimeSlot{sampleSlot(1_000_000)}); err != nil {
+		t.Fatalf("Write 2: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	// One header + 2 rows per sampleSlot call.
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d: %q", len(lines), buf.String())
+	}
+}
+
+func TestAggregatorFansOutToSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewCSVSink(&buf)
+
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000,
+		WindowSize: 2,
+		Sinks:      []Sink{sink},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	agg.AdvanceWindow(1_000_000, 1000)
+	agg.AdvanceWindow(5_000_000, 1000)
+
+	if err := agg.SinkError(); err != nil {
+		t.Fatalf("unexpected sink error: %v", err)
+	}
+	if err := agg.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if buf.Len() == 0 {
+		t.Fatal("expected the sink to have received at least the CSV header")
+	}
+}
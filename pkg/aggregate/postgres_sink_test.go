@@ -0,0 +1,67 @@
+package aggregate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPostgresConfigDefaults(t *testing.T) {
+	cfg := PostgresConfig{}
+	cfg.setDefaults()
+
+	if cfg.Table != "time_slot_metrics" {
+		t.Errorf("expected default table name, got %q", cfg.Table)
+	}
+	if cfg.MaxBatchRows != 1000 {
+		t.Errorf("expected default MaxBatchRows 1000, got %d", cfg.MaxBatchRows)
+	}
+	if cfg.MaxBatchAge != 5*time.Second {
+		t.Errorf("expected default MaxBatchAge 5s, got %v", cfg.MaxBatchAge)
+	}
+	if cfg.MaxRetries != 5 {
+		t.Errorf("expected default MaxRetries 5, got %d", cfg.MaxRetries)
+	}
+}
+
+func TestPostgresConfigDefaultsPreservesOverrides(t *testing.T) {
+	cfg := PostgresConfig{Table: "custom", MaxBatchRows: 10}
+	cfg.setDefaults()
+
+	if cfg.Table != "custom" {
+		t.Errorf("expected Table override preserved, got %q", cfg.Table)
+	}
+	if cfg.MaxBatchRows != 10 {
+		t.Errorf("expected MaxBatchRows override preserved, got %d", cfg.MaxBatchRows)
+	}
+}
+
+func TestIsTransientPgError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection exception", &pgconn.PgError{Code: "08006"}, true},
+		{"insufficient resources", &pgconn.PgError{Code: "53300"}, true},
+		{"operator intervention", &pgconn.PgError{Code: "57P01"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"generic network error", errors.New("connection reset by peer"), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransientPgError(tc.err); got != tc.want {
+				t.Errorf("isTransientPgError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	if got := quoteLiteral("it's a table"); got != "'it''s a table'" {
+		t.Errorf("unexpected quoting: %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package aggregate
+
+import "context"
+
+// Sink receives completed TimeSlots as they retire from the aggregator's
+// window, alongside (not instead of) the slice AdvanceWindow/Reset return to
+// the caller. Implementations may buffer internally; Flush forces any
+// buffered rows out, and Close releases underlying resources after a final
+// Flush.
+type Sink interface {
+	// Write hands a batch of completed slots to the sink. Implementations may
+	// buffer and return nil without having made them durable yet.
+	Write(ctx context.Context, slots []*TimeSlot) error
+	// Flush forces any buffered slots out to the underlying destination.
+	Flush(ctx context.Context) error
+	// Close flushes and releases any underlying resources (files, connections).
+	Close() error
+}
+
+// SinkError returns the first error encountered writing a completed TimeSlot
+// to any registered sink, if any. A sink write failure doesn't interrupt
+// aggregation: slots are still returned to the caller normally, so this must
+// be polled separately.
+func (a *Aggregator) SinkError() error {
+	return a.sinkErr
+}
+
+// writeSinks fans completed slots out to every registered sink, recording the
+// first failure in sinkErr rather than interrupting the caller's hot path.
+func (a *Aggregator) writeSinks(slots []*TimeSlot) {
+	if len(slots) == 0 {
+		return
+	}
+	for _, sink := range a.sinks {
+		if err := sink.Write(context.Background(), slots); err != nil && a.sinkErr == nil {
+			a.sinkErr = err
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package aggregate
+
+// Metric identifies a value tracked per RMID for threshold evaluation. The
+// first three are the raw counters accumulated directly in a
+// TimeSlotAggregation; IPC and LLCMissRate are derived from them.
+type Metric int
+
+const (
+	MetricCycles Metric = iota
+	MetricInstructions
+	MetricLLCMisses
+	// MetricIPC is Instructions/Cycles for the slot or high-water value in question.
+	MetricIPC
+	// MetricLLCMissRate is LLCMisses/Instructions for the slot or high-water value in question.
+	MetricLLCMissRate
+)
+
+func (m Metric) String() string {
+	switch m {
+	case MetricCycles:
+		return "cycles"
+	case MetricInstructions:
+		return "instructions"
+	case MetricLLCMisses:
+		return "llc_misses"
+	case MetricIPC:
+		return "ipc"
+	case MetricLLCMissRate:
+		return "llc_miss_rate"
+	default:
+		return "unknown"
+	}
+}
+
+// Comparison selects how a ThresholdRule's Threshold is compared against a metric.
+type Comparison int
+
+const (
+	// ComparisonValueGTE fires when the metric's value is >= Threshold.
+	ComparisonValueGTE Comparison = iota
+	// ComparisonRateGTE fires when the metric's value divided by the slot
+	// duration in seconds is >= Threshold (e.g. cycles/sec, LLC misses/sec).
+	ComparisonRateGTE
+)
+
+// ThresholdRule names a metric, how to compare it against Threshold, and how
+// long to wait after firing before the same RMID can trigger it again.
+type ThresholdRule struct {
+	Metric     Metric
+	Comparison Comparison
+	Threshold  float64
+	// Debounce is the minimum time, in nanoseconds of slot-end time, between
+	// consecutive events for the same RMID and rule.
+	Debounce uint64
+}
+
+// ThresholdEvent describes one threshold crossing, reported through the
+// Aggregator's alert sink.
+type ThresholdEvent struct {
+	RMID      uint32
+	Rule      ThresholdRule
+	SlotStart uint64
+	SlotEnd   uint64
+	// Value is the observed value that crossed Rule.Threshold: a raw metric
+	// value, an IPC/miss-rate ratio, or a per-second rate, depending on Rule.
+	Value float64
+}
+
+// rmidHighWater tracks, for one RMID, the maximum value seen for each metric
+// over the aggregator's lifetime (or since the last Reset), plus the last time
+// each configured rule fired so repeated crossings can be debounced.
+type rmidHighWater struct {
+	maxValue  map[Metric]float64
+	lastFired map[int]uint64 // rule index -> slot-end time (ns) it last fired at
+}
+
+func newRmidHighWater() *rmidHighWater {
+	return &rmidHighWater{
+		maxValue:  make(map[Metric]float64),
+		lastFired: make(map[int]uint64),
+	}
+}
+
+func (hw *rmidHighWater) observe(values map[Metric]float64) {
+	for metric, v := range values {
+		if v > hw.maxValue[metric] {
+			hw.maxValue[metric] = v
+		}
+	}
+}
+
+// metricValues computes the raw and derived metric values for one
+// TimeSlotAggregation snapshot. IPC and LLCMissRate are omitted when their
+// denominator is zero, since they're undefined in that case.
+func metricValues(agg *TimeSlotAggregation) map[Metric]float64 {
+	values := map[Metric]float64{
+		MetricCycles:       float64(agg.Cycles),
+		MetricInstructions: float64(agg.Instructions),
+		MetricLLCMisses:    float64(agg.LLCMisses),
+	}
+	if agg.Cycles > 0 {
+		values[MetricIPC] = float64(agg.Instructions) / float64(agg.Cycles)
+	}
+	if agg.Instructions > 0 {
+		values[MetricLLCMissRate] = float64(agg.LLCMisses) / float64(agg.Instructions)
+	}
+	return values
+}
+
+// SetAlertSink registers fn to be called for every ThresholdEvent fired while
+// evaluating Config.Thresholds. Passing nil disables alerting.
+func (a *Aggregator) SetAlertSink(fn func(rmid uint32, event ThresholdEvent)) {
+	a.alertSink = fn
+}
+
+// updateHighWater folds one slot aggregation's current values into the
+// running high-water mark for its RMID. Called from UpdateMeasurement after
+// the proportional split so the peak reflects every measurement, not just the
+// last one observed in a slot.
+func (a *Aggregator) updateHighWater(rmid uint32, agg *TimeSlotAggregation) {
+	hw, ok := a.highWater[rmid]
+	if !ok {
+		hw = newRmidHighWater()
+		a.highWater[rmid] = hw
+	}
+	hw.observe(metricValues(agg))
+}
+
+// evaluateThresholds checks every configured rule against a just-closed slot,
+// for every RMID that has an aggregation in it, firing the alert sink for any
+// rule that crosses its threshold and isn't still debounced.
+func (a *Aggregator) evaluateThresholds(slot *TimeSlot) {
+	if a.alertSink == nil || len(a.config.Thresholds) == 0 {
+		return
+	}
+
+	durationSeconds := float64(slot.EndTime-slot.StartTime) / 1e9
+
+	for rmid, agg := range slot.Aggregations {
+		slotValues := metricValues(agg)
+		hw := a.highWater[rmid]
+
+		for i, rule := range a.config.Thresholds {
+			candidate, ok := slotValues[rule.Metric]
+			if !ok {
+				candidate = 0
+			}
+			if hw != nil {
+				if hwVal, hwOk := hw.maxValue[rule.Metric]; hwOk && hwVal > candidate {
+					candidate = hwVal
+				}
+			}
+
+			var observed float64
+			var triggered bool
+			switch rule.Comparison {
+			case ComparisonRateGTE:
+				if durationSeconds <= 0 {
+					continue
+				}
+				observed = candidate / durationSeconds
+				triggered = observed >= rule.Threshold
+			default:
+				observed = candidate
+				triggered = observed >= rule.Threshold
+			}
+			if !triggered {
+				continue
+			}
+
+			if hw == nil {
+				hw = newRmidHighWater()
+				a.highWater[rmid] = hw
+			}
+			if lastFired, fired := hw.lastFired[i]; fired && safeSubtract(slot.EndTime, lastFired) < int64(rule.Debounce) {
+				continue
+			}
+			hw.lastFired[i] = slot.EndTime
+
+			a.alertSink(rmid, ThresholdEvent{
+				RMID:      rmid,
+				Rule:      rule,
+				SlotStart: slot.StartTime,
+				SlotEnd:   slot.EndTime,
+				Value:     observed,
+			})
+		}
+	}
+}
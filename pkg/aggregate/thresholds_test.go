@@ -0,0 +1,198 @@
+package aggregate
+
+import "testing"
+
+func TestThresholdFiresOnValueCrossing(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000, // 1ms
+		WindowSize: 2,
+		Thresholds: []ThresholdRule{
+			{Metric: MetricLLCMisses, Comparison: ComparisonValueGTE, Threshold: 50},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	var events []ThresholdEvent
+	agg.SetAlertSink(func(rmid uint32, e ThresholdEvent) {
+		events = append(events, e)
+	})
+
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 7, Cycles: 1000, Instructions: 2000, LLCMisses: 100,
+		Timestamp: 1_000_000, Duration: 1_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+
+	// Advance past the slot so it completes and gets evaluated.
+	agg.AdvanceWindow(5_000_000, 1000)
+
+	if len(events) != 1 {
+		t.Fatalf("expected exactly 1 threshold event, got %d: %+v", len(events), events)
+	}
+	if events[0].RMID != 7 {
+		t.Errorf("expected RMID 7, got %d", events[0].RMID)
+	}
+	if events[0].Value != 100 {
+		t.Errorf("expected observed value 100, got %f", events[0].Value)
+	}
+}
+
+func TestThresholdDoesNotFireBelowThreshold(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000,
+		WindowSize: 2,
+		Thresholds: []ThresholdRule{
+			{Metric: MetricLLCMisses, Comparison: ComparisonValueGTE, Threshold: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	var events []ThresholdEvent
+	agg.SetAlertSink(func(rmid uint32, e ThresholdEvent) { events = append(events, e) })
+
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 1, Cycles: 1000, Instructions: 2000, LLCMisses: 10,
+		Timestamp: 1_000_000, Duration: 1_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+	agg.AdvanceWindow(5_000_000, 1000)
+
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %+v", events)
+	}
+}
+
+func TestThresholdDebounceSuppressesRepeats(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000,
+		WindowSize: 1,
+		Thresholds: []ThresholdRule{
+			{Metric: MetricCycles, Comparison: ComparisonValueGTE, Threshold: 10, Debounce: 10_000_000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	var events []ThresholdEvent
+	agg.SetAlertSink(func(rmid uint32, e ThresholdEvent) { events = append(events, e) })
+
+	// Three consecutive slots, each well above threshold; debounce should only
+	// let the first one through within the 10ms window.
+	for i := uint64(1); i <= 3; i++ {
+		ts := i * 1_000_000
+		if err := agg.UpdateMeasurement(&Measurement{
+			RMID: 1, Cycles: 100, Instructions: 10, LLCMisses: 1,
+			Timestamp: ts, Duration: 1_000_000,
+		}); err != nil {
+			t.Fatalf("UpdateMeasurement: %v", err)
+		}
+		agg.AdvanceWindow(ts+1_000_000, 1000)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected exactly 1 event due to debounce, got %d: %+v", len(events), events)
+	}
+}
+
+func TestThresholdRateComparison(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000_000, // 1 second, so rate == raw value
+		WindowSize: 1,
+		Thresholds: []ThresholdRule{
+			{Metric: MetricInstructions, Comparison: ComparisonRateGTE, Threshold: 500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	var events []ThresholdEvent
+	agg.SetAlertSink(func(rmid uint32, e ThresholdEvent) { events = append(events, e) })
+
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 1, Cycles: 1000, Instructions: 1000, LLCMisses: 1,
+		Timestamp: 1_000_000_000, Duration: 1_000_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+	agg.AdvanceWindow(3_000_000_000, 1000)
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d: %+v", len(events), events)
+	}
+	if events[0].Value != 1000 {
+		t.Errorf("expected rate 1000/sec, got %f", events[0].Value)
+	}
+}
+
+func TestThresholdHighWaterPersistsAcrossSlots(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000,
+		WindowSize: 2,
+		Thresholds: []ThresholdRule{
+			{Metric: MetricCycles, Comparison: ComparisonValueGTE, Threshold: 500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	var events []ThresholdEvent
+	agg.SetAlertSink(func(rmid uint32, e ThresholdEvent) { events = append(events, e) })
+
+	// First slot has a big spike...
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 1, Cycles: 1000, Instructions: 10, LLCMisses: 1,
+		Timestamp: 1_000_000, Duration: 1_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+	// ...second slot is quiet.
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 1, Cycles: 10, Instructions: 10, LLCMisses: 1,
+		Timestamp: 2_000_000, Duration: 1_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+
+	agg.AdvanceWindow(10_000_000, 1000)
+
+	// One event for the spike slot itself, and one for the quiet slot that
+	// only crosses the threshold through the spike's high-water mark.
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events (spike slot and quiet slot), got %d: %+v", len(events), events)
+	}
+	if events[1].SlotStart != 1_000_000 || events[1].Value != 1000 {
+		t.Fatal("expected the high-water mark from the spike to still trigger on a later, quiet slot")
+	}
+}
+
+func TestResetClearsHighWaterState(t *testing.T) {
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000,
+		WindowSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	if err := agg.UpdateMeasurement(&Measurement{
+		RMID: 1, Cycles: 1000, Instructions: 10, LLCMisses: 1,
+		Timestamp: 1_000_000, Duration: 1_000_000,
+	}); err != nil {
+		t.Fatalf("UpdateMeasurement: %v", err)
+	}
+
+	agg.Reset()
+
+	if len(agg.highWater) != 0 {
+		t.Fatalf("expected highWater to be cleared after Reset, got %d entries", len(agg.highWater))
+	}
+}
@@ -0,0 +1,415 @@
+package aggregate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// SyncPolicy controls when a WALWriter flushes and fsyncs its segment file.
+type SyncPolicy int
+
+const (
+	// SyncNone never explicitly syncs; data is only as durable as the OS page cache.
+	SyncNone SyncPolicy = iota
+	// SyncInterval fsyncs at most once per SyncInterval, on the first write after it elapses.
+	SyncInterval
+	// SyncEveryRecord fsyncs after every record, trading throughput for durability.
+	SyncEveryRecord
+)
+
+// Compression selects the per-record compression codec used by the WAL.
+type Compression int
+
+const (
+	// CompressionNone stores the encoded TimeSlot as-is.
+	CompressionNone Compression = iota
+	// CompressionSnappy compresses the encoded TimeSlot with Snappy before writing it.
+	CompressionSnappy
+)
+
+// WALConfig configures durable write-ahead logging of completed time slots.
+type WALConfig struct {
+	// Dir is the directory segment files are written to and read from.
+	Dir string
+	// MaxSegmentBytes rotates to a new segment once the current one reaches this
+	// size. A value of 0 disables size-based rotation (only Rotate() applies).
+	MaxSegmentBytes int64
+	// Sync controls the fsync policy; see SyncPolicy.
+	Sync SyncPolicy
+	// SyncInterval is the minimum time between fsyncs when Sync is SyncInterval.
+	SyncInterval time.Duration
+	// Compression selects the per-record codec; see Compression.
+	Compression Compression
+}
+
+// ErrTruncatedRecord is returned by WALReader.Next when a segment ends in the
+// middle of a record, as happens if the writer crashed mid-write. The caller
+// can treat this as end-of-log: everything read so far is valid.
+var ErrTruncatedRecord = errors.New("aggregate: truncated WAL record")
+
+// ErrCorruptRecord is returned by WALReader.Next when a record's CRC doesn't
+// match its contents.
+var ErrCorruptRecord = errors.New("aggregate: WAL record failed CRC check")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+const walSegmentPrefix = "wal-"
+const walSegmentSuffix = ".seg"
+
+// WALWriter appends completed TimeSlots to a rotating sequence of segment files
+// under Dir. Each record is length-prefixed, optionally Snappy-compressed, and
+// trailed with a CRC-32C checksum so a reader can detect a truncated or
+// corrupted tail without misinterpreting the rest of the stream.
+type WALWriter struct {
+	cfg WALConfig
+
+	file        *os.File
+	buf         *bufio.Writer
+	segmentSize int64
+	nextIndex   int
+
+	lastSync time.Time
+}
+
+// NewWALWriter creates Dir if needed and opens a new segment to append to.
+func NewWALWriter(cfg WALConfig) (*WALWriter, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("aggregate: WALConfig.Dir must not be empty")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating WAL directory: %w", err)
+	}
+
+	existing, err := listSegments(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WALWriter{cfg: cfg}
+	if len(existing) > 0 {
+		w.nextIndex = existing[len(existing)-1] + 1
+	}
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func segmentPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%08d%s", walSegmentPrefix, index, walSegmentSuffix))
+}
+
+// listSegments returns the indices of every segment file in dir, sorted ascending.
+func listSegments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing WAL directory: %w", err)
+	}
+
+	var indices []int
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if len(name) != len(walSegmentPrefix)+8+len(walSegmentSuffix) {
+			continue
+		}
+		var index int
+		if _, err := fmt.Sscanf(name, walSegmentPrefix+"%08d"+walSegmentSuffix, &index); err != nil {
+			continue
+		}
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
+func (w *WALWriter) openSegment() error {
+	path := segmentPath(w.cfg.Dir, w.nextIndex)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %s: %w", path, err)
+	}
+	w.nextIndex++
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.segmentSize = 0
+	return nil
+}
+
+// Rotate closes the current segment and begins a new one, regardless of size.
+func (w *WALWriter) Rotate() error {
+	if err := w.flushAndSync(true); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("closing WAL segment: %w", err)
+	}
+	return w.openSegment()
+}
+
+// WriteSlot appends one completed TimeSlot as a single record, rotating to a
+// new segment first if MaxSegmentBytes would be exceeded.
+func (w *WALWriter) WriteSlot(slot *TimeSlot) error {
+	payload := encodeTimeSlot(slot)
+	if w.cfg.Compression == CompressionSnappy {
+		payload = snappy.Encode(nil, payload)
+	}
+
+	recordSize := int64(4 + len(payload) + 4)
+	if w.cfg.MaxSegmentBytes > 0 && w.segmentSize > 0 && w.segmentSize+recordSize > w.cfg.MaxSegmentBytes {
+		if err := w.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	var lenBuf [4]byte
+	binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+	if _, err := w.buf.Write(lenBuf[:]); err != nil {
+		return fmt.Errorf("writing WAL record length: %w", err)
+	}
+	if _, err := w.buf.Write(payload); err != nil {
+		return fmt.Errorf("writing WAL record payload: %w", err)
+	}
+
+	var crcBuf [4]byte
+	binary.LittleEndian.PutUint32(crcBuf[:], crc32.Checksum(payload, crcTable))
+	if _, err := w.buf.Write(crcBuf[:]); err != nil {
+		return fmt.Errorf("writing WAL record checksum: %w", err)
+	}
+
+	w.segmentSize += recordSize
+
+	forceSync := w.cfg.Sync == SyncEveryRecord
+	if err := w.flushAndSync(forceSync); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteSlots writes each slot in order, stopping at the first error.
+func (w *WALWriter) WriteSlots(slots []*TimeSlot) error {
+	for _, slot := range slots {
+		if err := w.WriteSlot(slot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WALWriter) flushAndSync(force bool) error {
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("flushing WAL segment: %w", err)
+	}
+
+	switch w.cfg.Sync {
+	case SyncNone:
+		return nil
+	case SyncEveryRecord:
+		force = true
+	case SyncInterval:
+		if !force && time.Since(w.lastSync) < w.cfg.SyncInterval {
+			return nil
+		}
+		force = true
+	}
+
+	if !force {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("syncing WAL segment: %w", err)
+	}
+	w.lastSync = time.Now()
+	return nil
+}
+
+// Close flushes, syncs, and closes the current segment.
+func (w *WALWriter) Close() error {
+	if err := w.flushAndSync(true); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+// WALReader iterates the TimeSlots recorded by a WALWriter, in segment and
+// append order, verifying each record's checksum as it goes. It must be given
+// the same Compression setting the WALWriter used, since that isn't recorded
+// in the stream itself.
+type WALReader struct {
+	dir         string
+	compression Compression
+	segments    []int
+	segIdx      int
+
+	file *os.File
+	r    *bufio.Reader
+}
+
+// NewWALReader opens cfg.Dir for reading, starting from its earliest segment.
+func NewWALReader(cfg WALConfig) (*WALReader, error) {
+	segments, err := listSegments(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	reader := &WALReader{dir: cfg.Dir, compression: cfg.Compression, segments: segments}
+	if err := reader.openNextSegment(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (r *WALReader) openNextSegment() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	if r.segIdx >= len(r.segments) {
+		return io.EOF
+	}
+	path := segmentPath(r.dir, r.segments[r.segIdx])
+	r.segIdx++
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %s: %w", path, err)
+	}
+	r.file = f
+	r.r = bufio.NewReader(f)
+	return nil
+}
+
+// Next returns the next TimeSlot in the log. It returns io.EOF once every
+// segment has been fully and cleanly consumed. If a segment ends partway
+// through a record (the writer crashed mid-write), Next returns
+// ErrTruncatedRecord instead of an io.ErrUnexpectedEOF-style error, so callers
+// can distinguish "nothing more to recover" from real corruption and simply
+// stop reading that segment.
+func (r *WALReader) Next() (*TimeSlot, error) {
+	for {
+		if r.file == nil {
+			return nil, io.EOF
+		}
+
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(r.r, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				// Clean end of this segment; move to the next one.
+				if openErr := r.openNextSegment(); openErr != nil {
+					return nil, openErr
+				}
+				continue
+			}
+			return nil, ErrTruncatedRecord
+		}
+		length := binary.LittleEndian.Uint32(lenBuf[:])
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r.r, payload); err != nil {
+			return nil, ErrTruncatedRecord
+		}
+
+		var crcBuf [4]byte
+		if _, err := io.ReadFull(r.r, crcBuf[:]); err != nil {
+			return nil, ErrTruncatedRecord
+		}
+		if binary.LittleEndian.Uint32(crcBuf[:]) != crc32.Checksum(payload, crcTable) {
+			return nil, ErrCorruptRecord
+		}
+
+		decoded := payload
+		if r.compression == CompressionSnappy {
+			decompressed, err := snappy.Decode(nil, payload)
+			if err != nil {
+				return nil, fmt.Errorf("%w: decompressing record: %v", ErrCorruptRecord, err)
+			}
+			decoded = decompressed
+		}
+
+		return decodeTimeSlot(decoded)
+	}
+}
+
+// Close closes the currently open segment, if any.
+func (r *WALReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// encodeTimeSlot serializes a TimeSlot into a flat, versionless binary form:
+// StartTime, EndTime, then a count-prefixed list of per-RMID aggregations.
+func encodeTimeSlot(slot *TimeSlot) []byte {
+	buf := make([]byte, 0, 20+len(slot.Aggregations)*28)
+	buf = binary.LittleEndian.AppendUint64(buf, slot.StartTime)
+	buf = binary.LittleEndian.AppendUint64(buf, slot.EndTime)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(slot.Aggregations)))
+
+	// Sort RMIDs so encoding is deterministic, which keeps WAL output diffable
+	// and makes tests reproducible.
+	rmids := make([]uint32, 0, len(slot.Aggregations))
+	for rmid := range slot.Aggregations {
+		rmids = append(rmids, rmid)
+	}
+	sort.Slice(rmids, func(i, j int) bool { return rmids[i] < rmids[j] })
+
+	for _, rmid := range rmids {
+		agg := slot.Aggregations[rmid]
+		buf = binary.LittleEndian.AppendUint32(buf, agg.RMID)
+		buf = binary.LittleEndian.AppendUint64(buf, agg.Cycles)
+		buf = binary.LittleEndian.AppendUint64(buf, agg.Instructions)
+		buf = binary.LittleEndian.AppendUint64(buf, agg.LLCMisses)
+		buf = binary.LittleEndian.AppendUint64(buf, agg.Duration)
+	}
+	return buf
+}
+
+// decodeTimeSlot is the inverse of encodeTimeSlot.
+func decodeTimeSlot(data []byte) (*TimeSlot, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("%w: record too short for a TimeSlot header", ErrCorruptRecord)
+	}
+	slot := &TimeSlot{
+		StartTime:    binary.LittleEndian.Uint64(data[0:8]),
+		EndTime:      binary.LittleEndian.Uint64(data[8:16]),
+		Aggregations: make(map[uint32]*TimeSlotAggregation),
+	}
+	count := binary.LittleEndian.Uint32(data[16:20])
+	offset := 20
+
+	for i := uint32(0); i < count; i++ {
+		if offset+28 > len(data) {
+			return nil, fmt.Errorf("%w: truncated aggregation entry", ErrCorruptRecord)
+		}
+		agg := &TimeSlotAggregation{
+			RMID:         binary.LittleEndian.Uint32(data[offset : offset+4]),
+			Cycles:       binary.LittleEndian.Uint64(data[offset+4 : offset+12]),
+			Instructions: binary.LittleEndian.Uint64(data[offset+12 : offset+20]),
+			LLCMisses:    binary.LittleEndian.Uint64(data[offset+20 : offset+28]),
+		}
+		offset += 28
+		if offset+8 > len(data) {
+			return nil, fmt.Errorf("%w: truncated aggregation entry", ErrCorruptRecord)
+		}
+		agg.Duration = binary.LittleEndian.Uint64(data[offset : offset+8])
+		offset += 8
+		slot.Aggregations[agg.RMID] = agg
+	}
+
+	return slot, nil
+}
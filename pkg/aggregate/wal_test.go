@@ -0,0 +1,329 @@
+package aggregate
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sampleSlot(start uint64) *TimeSlot {
+	return &TimeSlot{
+		StartTime: start,
+		EndTime:   start + 1_000_000,
+		Aggregations: map[uint32]*TimeSlotAggregation{
+			1: {RMID: 1, Cycles: 100, Instructions: 200, LLCMisses: 3, Duration: 1_000_000},
+			2: {RMID: 2, Cycles: 400, Instructions: 500, LLCMisses: 6, Duration: 500_000},
+		},
+	}
+}
+
+func TestWALWriteReadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{Dir: dir, Compression: CompressionSnappy}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+
+	want := []*TimeSlot{sampleSlot(0), sampleSlot(1_000_000), sampleSlot(2_000_000)}
+	for _, slot := range want {
+		if err := w.WriteSlot(slot); err != nil {
+			t.Fatalf("WriteSlot: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := NewWALReader(cfg)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	for i, wantSlot := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() at record %d: %v", i, err)
+		}
+		if got.StartTime != wantSlot.StartTime || got.EndTime != wantSlot.EndTime {
+			t.Errorf("record %d: expected times [%d,%d], got [%d,%d]", i, wantSlot.StartTime, wantSlot.EndTime, got.StartTime, got.EndTime)
+		}
+		if len(got.Aggregations) != len(wantSlot.Aggregations) {
+			t.Fatalf("record %d: expected %d aggregations, got %d", i, len(wantSlot.Aggregations), len(got.Aggregations))
+		}
+		for rmid, wantAgg := range wantSlot.Aggregations {
+			gotAgg, ok := got.Aggregations[rmid]
+			if !ok {
+				t.Fatalf("record %d: missing RMID %d", i, rmid)
+			}
+			if *gotAgg != *wantAgg {
+				t.Errorf("record %d RMID %d: expected %+v, got %+v", i, rmid, wantAgg, gotAgg)
+			}
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after all records consumed, got %v", err)
+	}
+}
+
+func TestWALWriteReadRoundTripUncompressed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{Dir: dir, Compression: CompressionNone}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(42)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := NewWALReader(cfg)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	got, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if got.StartTime != 42 {
+		t.Errorf("expected StartTime 42, got %d", got.StartTime)
+	}
+}
+
+func TestWALRotateOnSegmentSize(t *testing.T) {
+	dir := t.TempDir()
+	// A tiny MaxSegmentBytes forces a rotation on every record after the first.
+	cfg := WALConfig{Dir: dir, Compression: CompressionSnappy, MaxSegmentBytes: 1}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.WriteSlot(sampleSlot(uint64(i) * 1_000_000)); err != nil {
+			t.Fatalf("WriteSlot %d: %v", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 segment files after rotation, got %d", len(entries))
+	}
+
+	r, err := NewWALReader(cfg)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	count := 0
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("expected to read back 3 records, got %d", count)
+	}
+}
+
+func TestWALExplicitRotate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{Dir: dir}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(0)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(1_000_000)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 segments after explicit rotate, got %d", len(entries))
+	}
+}
+
+func TestWALReaderDetectsTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{Dir: dir, Compression: CompressionSnappy}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(0)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(1_000_000)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Truncate the segment file partway through the second record.
+	segments, err := listSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected exactly one segment, got %v (err %v)", segments, err)
+	}
+	path := segmentPath(dir, segments[0])
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-2); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	r, err := NewWALReader(cfg)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("expected first record to read cleanly, got error: %v", err)
+	}
+	if _, err := r.Next(); !errors.Is(err, ErrTruncatedRecord) {
+		t.Fatalf("expected ErrTruncatedRecord for the truncated second record, got %v", err)
+	}
+}
+
+func TestWALReaderDetectsCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{Dir: dir, Compression: CompressionNone}
+
+	w, err := NewWALWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.WriteSlot(sampleSlot(0)); err != nil {
+		t.Fatalf("WriteSlot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected exactly one segment, got %v (err %v)", segments, err)
+	}
+	path := segmentPath(dir, segments[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Flip a byte in the middle of the payload, past the 4-byte length prefix.
+	data[10] ^= 0xFF
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	r, err := NewWALReader(cfg)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Next(); !errors.Is(err, ErrCorruptRecord) {
+		t.Fatalf("expected ErrCorruptRecord, got %v", err)
+	}
+}
+
+func TestAggregatorWritesCompletedSlotsToWAL(t *testing.T) {
+	dir := t.TempDir()
+	agg, err := NewAggregator(Config{
+		SlotLength: 1_000_000, // 1ms
+		WindowSize: 2,
+		WAL:        &WALConfig{Dir: dir, Compression: CompressionSnappy},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator: %v", err)
+	}
+
+	// Advance far enough that earlier slots retire and get flushed to the WAL.
+	agg.AdvanceWindow(1_000_000, 1000)
+	agg.AdvanceWindow(5_000_000, 1000)
+
+	if err := agg.WALError(); err != nil {
+		t.Fatalf("unexpected WAL error: %v", err)
+	}
+	if err := agg.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := NewWALReader(WALConfig{Dir: dir, Compression: CompressionSnappy})
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	count := 0
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		count++
+	}
+	if count == 0 {
+		t.Fatal("expected at least one completed slot to be written to the WAL")
+	}
+}
+
+func TestSegmentPathRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := segmentPath(dir, 7)
+	if filepath.Dir(path) != dir {
+		t.Fatalf("expected segment path under %s, got %s", dir, path)
+	}
+	if err := os.WriteFile(path, []byte{}, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments: %v", err)
+	}
+	if len(segments) != 1 || segments[0] != 7 {
+		t.Fatalf("expected segments [7], got %v", segments)
+	}
+}
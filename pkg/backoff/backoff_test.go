@@ -11,10 +11,16 @@ import (
 	"github.com/cilium/ebpf/rlimit"
 )
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -type backoff_should_try_input -type backoff_should_try_output BackoffTest backoff_test.bpf.c -- -I.
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -type backoff_should_try_input -type backoff_should_try_output -type backoff_init_policy_input -type backoff_update_failure_v2_input BackoffTest backoff_test.bpf.c -- -I.
 
 const XDP_PASS = 2
 
+// Backoff policies, mirroring BACKOFF_POLICY_* in backoff_test.bpf.c.
+const (
+	PolicyExp          = uint8(0)
+	PolicyDecorrJitter = uint8(1)
+)
+
 // Wrapper functions
 func BackoffInit(objs *BackoffTestObjects) error {
 	ret, _, err := objs.BackoffTestPrograms.WrapBackoffInit.Test([]byte{})
@@ -49,6 +55,64 @@ func BackoffUpdateFailure(objs *BackoffTestObjects) error {
 	return nil
 }
 
+// BackoffInitPolicy resets the per-CPU backoff state for the given policy.
+// base and cap only matter for PolicyDecorrJitter; PolicyExp ignores them.
+func BackoffInitPolicy(objs *BackoffTestObjects, policy uint8, base, cap uint32) error {
+	input := BackoffTestBackoffInitPolicyInput{
+		Policy: policy,
+		Base:   base,
+		Cap:    cap,
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, input); err != nil {
+		return fmt.Errorf("serializing input: %w", err)
+	}
+
+	ret, _, err := objs.BackoffTestPrograms.WrapBackoffInitPolicy.Test(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("calling test function: %w", err)
+	}
+	if ret != XDP_PASS {
+		return fmt.Errorf("test function returned non-zero: %d", ret)
+	}
+	return nil
+}
+
+func BackoffUpdateSuccessV2(objs *BackoffTestObjects) error {
+	ret, _, err := objs.BackoffTestPrograms.WrapBackoffUpdateSuccessV2.Test([]byte{})
+	if err != nil {
+		return fmt.Errorf("calling test function: %w", err)
+	}
+	if ret != XDP_PASS {
+		return fmt.Errorf("test function returned non-zero: %d", ret)
+	}
+	return nil
+}
+
+// BackoffUpdateFailureV2 draws the next decorrelated-jitter sleep budget,
+// using randomValue as the source of randomness in place of
+// bpf_get_prandom_u32 so tests are reproducible.
+func BackoffUpdateFailureV2(objs *BackoffTestObjects, randomValue uint32) error {
+	input := BackoffTestBackoffUpdateFailureV2Input{
+		RandomValue: randomValue,
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, input); err != nil {
+		return fmt.Errorf("serializing input: %w", err)
+	}
+
+	ret, _, err := objs.BackoffTestPrograms.WrapBackoffUpdateFailureV2.Test(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("calling test function: %w", err)
+	}
+	if ret != XDP_PASS {
+		return fmt.Errorf("test function returned non-zero: %d", ret)
+	}
+	return nil
+}
+
 func BackoffInBackoff(objs *BackoffTestObjects) (bool, error) {
 	ret, result, err := objs.BackoffTestPrograms.WrapBackoffInBackoff.Test([]byte{1 /* must be non-empty */})
 	if err != nil {
@@ -280,3 +344,86 @@ func TestBackoffProbability(t *testing.T) {
 		}
 	}
 }
+
+// TestBackoffDecorrJitterProbability drives the decorrelated-jitter policy
+// through a run of failures to build up a sleep budget S, then checks that
+// BackoffShouldTry grants with the expected base/S probability - mirroring
+// TestBackoffProbability's approach for the exponential policy above.
+func TestBackoffDecorrJitterProbability(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Fatalf("Failed to remove memlock limit: %v", err)
+	}
+
+	objs := BackoffTestObjects{}
+	if err := LoadBackoffTestObjects(&objs, nil); err != nil {
+		t.Fatalf("Failed to load objects: %v", err)
+	}
+	defer objs.Close()
+
+	const base = uint32(100)
+	const cap = uint32(10000)
+
+	if err := BackoffInitPolicy(&objs, PolicyDecorrJitter, base, cap); err != nil {
+		t.Fatalf("Failed to initialize decorr jitter backoff: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(7))
+
+	// Drive a handful of failures so S grows well past base, tracking the
+	// same recurrence in Go so we know the expected grant probability.
+	s := base
+	failures := 6
+	for i := 0; i < failures; i++ {
+		rv := r.Uint32()
+		if err := BackoffUpdateFailureV2(&objs, rv); err != nil {
+			t.Fatalf("Failed to update failure: %v", err)
+		}
+
+		upper := uint64(s) * 3
+		var span uint64
+		if upper <= uint64(base) {
+			s = base
+			continue
+		}
+		span = upper - uint64(base)
+		next := base + uint32((uint64(rv)*span)>>32)
+		if next > cap {
+			next = cap
+		}
+		s = next
+	}
+	if s <= base {
+		t.Fatalf("test setup failed to grow S past base: s=%d", s)
+	}
+
+	expectedProbability := float64(base) / float64(s)
+
+	tries := 10000
+	successes := 0
+	for i := 0; i < tries; i++ {
+		shouldTry, err := BackoffShouldTry(&objs, r.Uint32())
+		if err != nil {
+			t.Fatalf("Failed to check should try: %v", err)
+		}
+		if shouldTry {
+			successes++
+		}
+	}
+
+	actualProbability := float64(successes) / float64(tries)
+	if actualProbability < expectedProbability*0.80 || actualProbability > expectedProbability*1.2 {
+		t.Errorf("Expected probability %.4f, got %.4f", expectedProbability, actualProbability)
+	}
+
+	// A success resets S back to base, so should_try should always grant.
+	if err := BackoffUpdateSuccessV2(&objs); err != nil {
+		t.Fatalf("Failed to update success: %v", err)
+	}
+	shouldTry, err := BackoffShouldTry(&objs, r.Uint32())
+	if err != nil {
+		t.Fatalf("Failed to check should try: %v", err)
+	}
+	if !shouldTry {
+		t.Error("Expected should try to be true immediately after success resets S to base")
+	}
+}
@@ -0,0 +1,262 @@
+// Package cgroup resolves cgroup v2 inode identifiers (as returned by the kernel's
+// bpf_get_current_cgroup_id()) into the Kubernetes pod/container that owns them, by
+// walking the kubelet's standard cgroup directory layout.
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Info describes the workload that owns a cgroup.
+type Info struct {
+	// Path is the cgroup's path under the mount root, e.g.
+	// "/kubepods.slice/kubepods-burstable.slice/.../cri-containerd-<id>.scope"
+	Path string
+	// PodUID is the Kubernetes pod UID, if the cgroup belongs to a pod.
+	PodUID string
+	// Container is the container ID as embedded in the cgroup leaf directory name.
+	Container string
+	// Namespace is the Kubernetes namespace. It cannot be derived from the cgroup
+	// path alone, so it is left empty unless a caller sets it via a future lookup
+	// against the container runtime or the Kubernetes API.
+	Namespace string
+}
+
+// systemd driver: kubepods-burstable-pod<uid_with_underscores>.slice:cri-containerd:<id>.scope (flattened)
+// or nested: .../kubepods-burstable-pod<uid>.slice/cri-containerd-<id>.scope
+var podSystemdRE = regexp.MustCompile(`kubepods-[^-]+-pod([a-f0-9_]{32,36})\.slice`)
+var containerSystemdRE = regexp.MustCompile(`(?:cri-containerd|docker|crio)-([0-9a-f]{64})\.scope`)
+
+// cgroupfs driver: .../kubepods/burstable/pod<uid-with-dashes>/<container-id>
+var podCgroupfsRE = regexp.MustCompile(`pod([0-9a-f-]{36})`)
+var containerCgroupfsRE = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// parsePath extracts pod/container identifiers from a cgroup path using the two
+// directory layouts the kubelet supports (systemd and cgroupfs cgroup drivers).
+func parsePath(path string) Info {
+	info := Info{Path: path}
+
+	if m := podSystemdRE.FindStringSubmatch(path); m != nil {
+		info.PodUID = normalizeUID(m[1])
+	} else if m := podCgroupfsRE.FindStringSubmatch(path); m != nil {
+		info.PodUID = m[1]
+	}
+
+	if m := containerSystemdRE.FindStringSubmatch(path); m != nil {
+		info.Container = m[1]
+	} else {
+		base := filepath.Base(path)
+		if containerCgroupfsRE.MatchString(base) {
+			info.Container = base
+		}
+	}
+
+	return info
+}
+
+// normalizeUID turns the underscore-separated UID systemd embeds in unit names
+// (e.g. "ab1234ef_5678_...") back into the dashed form Kubernetes uses elsewhere.
+func normalizeUID(raw string) string {
+	b := []byte(raw)
+	// pod UIDs are 32 hex digits grouped 8-4-4-4-12, systemd replaces '-' with '_'
+	for _, i := range []int{8, 13, 18, 23} {
+		if i < len(b) {
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
+
+// cgroupEntry is the cached result of resolving a single cgroup id.
+type cgroupEntry struct {
+	info     Info
+	lastSeen time.Time
+}
+
+// negativeResolveTTL bounds how long Resolve will skip re-scanning for a
+// cgroup id that wasn't found, before trying again. It's short relative to
+// EvictStale's typical maxAge since a miss can mean "not created yet" just
+// as often as "already gone".
+const negativeResolveTTL = 2 * time.Second
+
+// Resolver maps cgroup v2 inode ids to the pod/container that owns them. It lazily
+// walks /sys/fs/cgroup on a cache miss and keeps an in-memory cache bounded by
+// EvictStale so cardinality doesn't grow unbounded as containers churn.
+type Resolver struct {
+	root string
+
+	mu      sync.RWMutex
+	byID    map[uint64]*cgroupEntry
+	missAt  map[uint64]time.Time // cgroup ids that didn't resolve, and when that was last confirmed
+	onEvict func(id uint64, info Info)
+}
+
+// NewResolver creates a Resolver rooted at the given cgroup v2 mount (typically
+// "/sys/fs/cgroup").
+func NewResolver(root string) *Resolver {
+	return &Resolver{
+		root:   root,
+		byID:   make(map[uint64]*cgroupEntry),
+		missAt: make(map[uint64]time.Time),
+	}
+}
+
+// OnEvict registers a callback invoked whenever EvictStale drops a cached entry, so
+// callers (e.g. a Prometheus exporter) can remove the corresponding label set.
+func (r *Resolver) OnEvict(fn func(id uint64, info Info)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvict = fn
+}
+
+// Resolve returns the cached Info for cgroupID, performing a full walk of root on a
+// cache miss. The second return value is false if the cgroup could not be found
+// (e.g. it has already been removed). A cgroup id that still doesn't resolve after
+// a walk is itself cached for negativeResolveTTL, so a hot read loop repeatedly
+// asking about an id that's already gone pays for one walk per TTL window rather
+// than one walk per call.
+func (r *Resolver) Resolve(cgroupID uint64) (Info, bool) {
+	r.mu.RLock()
+	entry, ok := r.byID[cgroupID]
+	missedAt, missed := r.missAt[cgroupID]
+	r.mu.RUnlock()
+	if ok {
+		r.touch(cgroupID)
+		return entry.info, true
+	}
+	if missed && time.Since(missedAt) < negativeResolveTTL {
+		return Info{}, false
+	}
+
+	if err := r.scan(time.Now()); err != nil {
+		return Info{}, false
+	}
+
+	r.mu.Lock()
+	entry, ok = r.byID[cgroupID]
+	if !ok {
+		r.missAt[cgroupID] = time.Now()
+	} else {
+		delete(r.missAt, cgroupID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return Info{}, false
+	}
+	return entry.info, true
+}
+
+// scan walks the cgroup tree once, stamping each directory's cache entry with now.
+func (r *Resolver) scan(now time.Time) error {
+	return filepath.WalkDir(r.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear mid-walk (container exit); skip them.
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.root, path)
+		if err != nil {
+			rel = path
+		}
+		info := parsePath("/" + rel)
+
+		r.mu.Lock()
+		if e, exists := r.byID[stat.Ino]; exists {
+			e.lastSeen = now
+		} else {
+			r.byID[stat.Ino] = &cgroupEntry{info: info, lastSeen: now}
+		}
+		r.mu.Unlock()
+
+		return nil
+	})
+}
+
+func (r *Resolver) touch(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.byID[id]; ok {
+		e.lastSeen = time.Now()
+	}
+}
+
+// EvictStale drops cached entries whose cgroup directory could no longer be found
+// during the last scan that observed them, i.e. whose lastSeen is older than maxAge.
+// It should be called periodically (e.g. from a dedicated goroutine) after a fresh
+// scan so cardinality stays bounded as containers are created and removed.
+func (r *Resolver) EvictStale(maxAge time.Duration) int {
+	now := time.Now()
+	if err := r.scan(now); err != nil {
+		return 0
+	}
+
+	cutoff := now.Add(-maxAge)
+	type evictedEntry struct {
+		id   uint64
+		info Info
+	}
+	var evicted []evictedEntry
+
+	r.mu.Lock()
+	for id, e := range r.byID {
+		if e.lastSeen.Before(cutoff) {
+			evicted = append(evicted, evictedEntry{id: id, info: e.info})
+			delete(r.byID, id)
+		}
+	}
+	for id, missedAt := range r.missAt {
+		if time.Since(missedAt) >= negativeResolveTTL {
+			delete(r.missAt, id)
+		}
+	}
+	onEvict := r.onEvict
+	r.mu.Unlock()
+
+	if onEvict != nil {
+		for _, e := range evicted {
+			onEvict(e.id, e.info)
+		}
+	}
+
+	return len(evicted)
+}
+
+// Run starts a goroutine that calls EvictStale every interval until stop is closed.
+// This bounds the cardinality of any per-cgroup metrics derived from Resolve.
+func (r *Resolver) Run(interval, maxAge time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.EvictStale(maxAge)
+			}
+		}
+	}()
+}
+
+// String implements fmt.Stringer for debugging.
+func (i Info) String() string {
+	return fmt.Sprintf("cgroup{path=%s pod=%s container=%s}", i.Path, i.PodUID, i.Container)
+}
@@ -0,0 +1,163 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParsePathSystemd(t *testing.T) {
+	path := "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234abcd_5678_90ab_cdef_1234567890ab.slice/cri-containerd-" +
+		"deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef.scope"
+
+	info := parsePath(path)
+	if info.PodUID != "1234abcd-5678-90ab-cdef-1234567890ab" {
+		t.Errorf("unexpected pod uid: %q", info.PodUID)
+	}
+	if info.Container != "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef" {
+		t.Errorf("unexpected container id: %q", info.Container)
+	}
+}
+
+func TestParsePathCgroupfs(t *testing.T) {
+	path := "/kubepods/burstable/pod1234abcd-5678-90ab-cdef-1234567890ab/" +
+		"deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+
+	info := parsePath(path)
+	if info.PodUID != "1234abcd-5678-90ab-cdef-1234567890ab" {
+		t.Errorf("unexpected pod uid: %q", info.PodUID)
+	}
+	if info.Container != "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef" {
+		t.Errorf("unexpected container id: %q", info.Container)
+	}
+}
+
+func TestResolverResolve(t *testing.T) {
+	root := t.TempDir()
+	containerDir := filepath.Join(root, "kubepods", "burstable",
+		"pod1234abcd-5678-90ab-cdef-1234567890ab",
+		"deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("failed to create test cgroup tree: %v", err)
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(containerDir, &stat); err != nil {
+		t.Fatalf("failed to stat test cgroup dir: %v", err)
+	}
+
+	r := NewResolver(root)
+	info, ok := r.Resolve(stat.Ino)
+	if !ok {
+		t.Fatalf("expected to resolve cgroup id %d", stat.Ino)
+	}
+	if info.PodUID != "1234abcd-5678-90ab-cdef-1234567890ab" {
+		t.Errorf("unexpected pod uid: %q", info.PodUID)
+	}
+	if info.Container == "" {
+		t.Errorf("expected container id to be resolved")
+	}
+
+	if _, ok := r.Resolve(^uint64(0)); ok {
+		t.Errorf("expected unknown cgroup id to not resolve")
+	}
+}
+
+func TestResolverEvictStale(t *testing.T) {
+	root := t.TempDir()
+	containerDir := filepath.Join(root, "kubepods", "burstable", "pod-uid", "container-id")
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("failed to create test cgroup tree: %v", err)
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(containerDir, &stat); err != nil {
+		t.Fatalf("failed to stat test cgroup dir: %v", err)
+	}
+
+	r := NewResolver(root)
+	if _, ok := r.Resolve(stat.Ino); !ok {
+		t.Fatalf("expected to resolve cgroup id %d", stat.Ino)
+	}
+
+	var evictedID uint64
+	evictedCount := 0
+	r.OnEvict(func(id uint64, info Info) {
+		evictedID = id
+		evictedCount++
+	})
+
+	if err := os.Remove(containerDir); err != nil {
+		t.Fatalf("failed to remove test cgroup dir: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// The entry was last seen "now", so a long maxAge should not evict it yet.
+	if n := r.EvictStale(time.Hour); n != 0 {
+		t.Errorf("expected no evictions with a long maxAge, got %d", n)
+	}
+
+	// A maxAge of 0 means anything not re-observed in this scan is stale.
+	if n := r.EvictStale(0); n != 1 {
+		t.Errorf("expected 1 eviction, got %d", n)
+	}
+	if evictedCount != 1 || evictedID != stat.Ino {
+		t.Errorf("expected eviction callback for id %d, got count=%d id=%d", stat.Ino, evictedCount, evictedID)
+	}
+
+	if _, ok := r.Resolve(stat.Ino); ok {
+		t.Errorf("expected cgroup id to no longer resolve after eviction")
+	}
+}
+
+func TestResolverCachesNegativeLookups(t *testing.T) {
+	root := t.TempDir()
+	r := NewResolver(root)
+
+	unknown := ^uint64(0)
+	if _, ok := r.Resolve(unknown); ok {
+		t.Fatalf("expected unknown cgroup id to not resolve")
+	}
+
+	// Create a directory after the first miss. Misses are cached per id, so
+	// the new directory's id still resolves on its first lookup, while the
+	// unknown id keeps reporting a miss within negativeResolveTTL.
+	containerDir := filepath.Join(root, "kubepods", "burstable", "pod-uid", "container-id")
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("failed to create test cgroup tree: %v", err)
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(containerDir, &stat); err != nil {
+		t.Fatalf("failed to stat test cgroup dir: %v", err)
+	}
+
+	if stat.Ino == unknown {
+		t.Fatalf("test setup collision: new cgroup reused the probed inode")
+	}
+	if _, ok := r.Resolve(stat.Ino); !ok {
+		t.Fatalf("expected newly created cgroup id to resolve on its own first lookup")
+	}
+
+	if _, ok := r.Resolve(unknown); ok {
+		t.Errorf("expected the still-unknown id to keep reporting a miss")
+	}
+
+	r.mu.RLock()
+	missedAt, ok := r.missAt[unknown]
+	r.mu.RUnlock()
+	if !ok {
+		t.Fatalf("expected the miss to be cached")
+	}
+
+	// Force the cached miss to look expired, so the next Resolve re-scans
+	// instead of trusting the stale negative result.
+	r.mu.Lock()
+	r.missAt[unknown] = missedAt.Add(-2 * negativeResolveTTL)
+	r.mu.Unlock()
+
+	if _, ok := r.Resolve(unknown); ok {
+		t.Errorf("expected the id to still not resolve after the cached miss expired and a fresh scan ran")
+	}
+}
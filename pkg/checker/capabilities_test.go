@@ -0,0 +1,61 @@
+package checker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMonDir(t *testing.T, root, features, numRMIDs string) {
+	t.Helper()
+	monDir := filepath.Join(root, "info", "L3_MON")
+	if err := os.MkdirAll(monDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(monDir, "mon_features"), []byte(features), 0644); err != nil {
+		t.Fatalf("WriteFile mon_features: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(monDir, "num_rmids"), []byte(numRMIDs), 0644); err != nil {
+		t.Fatalf("WriteFile num_rmids: %v", err)
+	}
+}
+
+func TestDiscoverCapabilities(t *testing.T) {
+	root := t.TempDir()
+	writeMonDir(t, root, "llc_occupancy\nmbm_total_bytes\nmbm_local_bytes\n", "192\n")
+
+	caps, err := DiscoverCapabilities(root)
+	if err != nil {
+		t.Fatalf("DiscoverCapabilities: %v", err)
+	}
+	if !caps.CMT || !caps.MBM {
+		t.Errorf("expected CMT and MBM both true, got %+v", caps)
+	}
+	if caps.NumRMIDs != 192 {
+		t.Errorf("expected NumRMIDs 192, got %d", caps.NumRMIDs)
+	}
+	if len(caps.MonFeatures) != 3 {
+		t.Errorf("expected 3 mon features, got %v", caps.MonFeatures)
+	}
+}
+
+func TestDiscoverCapabilitiesMissingL3Mon(t *testing.T) {
+	root := t.TempDir()
+	if _, err := DiscoverCapabilities(root); !errors.Is(err, ErrKernelMissingCMT) {
+		t.Fatalf("expected ErrKernelMissingCMT, got %v", err)
+	}
+}
+
+func TestDiscoverCapabilitiesWithoutMBM(t *testing.T) {
+	root := t.TempDir()
+	writeMonDir(t, root, "llc_occupancy\n", "32\n")
+
+	caps, err := DiscoverCapabilities(root)
+	if err != nil {
+		t.Fatalf("DiscoverCapabilities: %v", err)
+	}
+	if !caps.CMT || caps.MBM {
+		t.Errorf("expected CMT only, got %+v", caps)
+	}
+}
@@ -0,0 +1,57 @@
+// Package checker detects and mounts the Linux resctrl filesystem, discovers
+// its monitoring capabilities (CMT/MBM), and manages monitoring groups so a
+// long-running collector can attribute RDT events to cgroups.
+package checker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoRMIDsAvailable is returned by MonGroupManager.Allocate when the
+// hardware's RMID pool (Capabilities.NumRMIDs) is already fully assigned.
+var ErrNoRMIDsAvailable = errors.New("checker: no RMIDs available")
+
+// ErrKernelMissingCMT is returned when the running kernel's resctrl mount
+// doesn't expose L3 cache occupancy monitoring (CMT), so per-cgroup memory
+// bandwidth/occupancy attribution isn't possible on this host.
+var ErrKernelMissingCMT = errors.New("checker: kernel does not support CMT (L3_MON not available)")
+
+// NotFoundError is returned when a requested resource control mountpoint
+// could not be located.
+type NotFoundError struct {
+	Resource string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("checker: mountpoint for %s not found", e.Resource)
+}
+
+// IsNotFound reports whether err is (or wraps) a *NotFoundError.
+func IsNotFound(err error) bool {
+	var nf *NotFoundError
+	return errors.As(err, &nf)
+}
+
+// CheckResctrlSupport ensures resctrl is mounted with the given options and
+// that the kernel exposes the monitoring features the collector needs,
+// returning the discovered Capabilities on success. Unlike a CLI tool, it
+// never exits the process: callers embedding this in a long-running
+// collector are expected to handle the error themselves (e.g. log and
+// disable RDT-based features).
+func CheckResctrlSupport(opts ResctrlOptions) (string, Capabilities, error) {
+	mountPoint, err := EnsureMounted(opts)
+	if err != nil {
+		return "", Capabilities{}, fmt.Errorf("mounting resctrl: %w", err)
+	}
+
+	caps, err := DiscoverCapabilities(mountPoint)
+	if err != nil {
+		return mountPoint, Capabilities{}, err
+	}
+	if !caps.CMT {
+		return mountPoint, caps, ErrKernelMissingCMT
+	}
+
+	return mountPoint, caps, nil
+}
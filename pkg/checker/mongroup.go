@@ -0,0 +1,167 @@
+package checker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monGroupEvictionInterval is how often MonGroupManager polls for cgroups
+// whose monitoring groups it owns having been removed.
+const monGroupEvictionInterval = 5 * time.Second
+
+// MonGroup is a single resctrl monitoring group allocated for a cgroup.
+type MonGroup struct {
+	// Name is the monitoring group's directory name under mon_groups/.
+	Name string
+	// Path is the monitoring group's full path (mountPoint/mon_groups/Name).
+	Path string
+	// CgroupPath is the cgroup v2 path this group tracks, as passed to Allocate.
+	CgroupPath string
+}
+
+// MonGroupManager allocates and reclaims resctrl monitoring groups for a set
+// of cgroup v2 paths, bounded by the hardware's RMID pool. Once a cgroup is
+// removed from the host, its monitoring group is reclaimed automatically by
+// a background polling loop.
+type MonGroupManager struct {
+	mountPoint string
+	maxRMIDs   int
+
+	mu     sync.Mutex
+	groups map[string]*MonGroup // keyed by Name
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMonGroupManager creates a manager bounded by caps.NumRMIDs. Call Close
+// to stop its background eviction loop.
+func NewMonGroupManager(mountPoint string, caps Capabilities) *MonGroupManager {
+	m := &MonGroupManager{
+		mountPoint: mountPoint,
+		maxRMIDs:   caps.NumRMIDs,
+		groups:     make(map[string]*MonGroup),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go m.evictLoop()
+	return m
+}
+
+// Allocate creates a new monitoring group named name, tracking cgroupPath by
+// writing its tasks into the group's tasks file. It returns
+// ErrNoRMIDsAvailable if every RMID the hardware supports is already
+// assigned to a live monitoring group.
+func (m *MonGroupManager) Allocate(name, cgroupPath string) (*MonGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.groups[name]; exists {
+		return nil, fmt.Errorf("checker: monitoring group %q already allocated", name)
+	}
+	if m.maxRMIDs > 0 && len(m.groups) >= m.maxRMIDs {
+		return nil, ErrNoRMIDsAvailable
+	}
+
+	groupPath := filepath.Join(m.mountPoint, "mon_groups", name)
+	if err := os.MkdirAll(groupPath, 0755); err != nil {
+		return nil, fmt.Errorf("creating monitoring group %s: %w", groupPath, err)
+	}
+
+	if err := m.assignTasks(groupPath, cgroupPath); err != nil {
+		os.RemoveAll(groupPath)
+		return nil, err
+	}
+
+	group := &MonGroup{Name: name, Path: groupPath, CgroupPath: cgroupPath}
+	m.groups[name] = group
+	return group, nil
+}
+
+// assignTasks writes every process ID currently listed in the cgroup's
+// cgroup.procs into the monitoring group's tasks file, one write per ID, so
+// resctrl starts attributing that cgroup's activity to this group's RMID.
+// resctrl moves only the task whose ID is written, so threads other than
+// each process's main thread are not moved.
+func (m *MonGroupManager) assignTasks(groupPath, cgroupPath string) error {
+	procsRaw, err := os.ReadFile(filepath.Join(cgroupPath, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filepath.Join(cgroupPath, "cgroup.procs"), err)
+	}
+
+	tasksFile, err := os.OpenFile(filepath.Join(groupPath, "tasks"), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", filepath.Join(groupPath, "tasks"), err)
+	}
+	defer tasksFile.Close()
+
+	for _, pid := range strings.Fields(string(procsRaw)) {
+		if _, err := tasksFile.WriteString(pid); err != nil {
+			return fmt.Errorf("assigning task %s to %s: %w", pid, groupPath, err)
+		}
+	}
+	return nil
+}
+
+// Reclaim removes the named monitoring group, freeing its RMID for reuse.
+// It is a no-op if name isn't currently allocated.
+func (m *MonGroupManager) Reclaim(name string) error {
+	m.mu.Lock()
+	group, exists := m.groups[name]
+	if !exists {
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.groups, name)
+	m.mu.Unlock()
+
+	if err := os.RemoveAll(group.Path); err != nil {
+		return fmt.Errorf("removing monitoring group %s: %w", group.Path, err)
+	}
+	return nil
+}
+
+// evictLoop periodically reclaims monitoring groups whose backing cgroup has
+// been removed from the host, so a crashed or forgotten caller doesn't leak
+// RMIDs indefinitely.
+func (m *MonGroupManager) evictLoop() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(monGroupEvictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.evictRemovedCgroups()
+		}
+	}
+}
+
+func (m *MonGroupManager) evictRemovedCgroups() {
+	m.mu.Lock()
+	var stale []string
+	for name, group := range m.groups {
+		if _, err := os.Stat(group.CgroupPath); os.IsNotExist(err) {
+			stale = append(stale, name)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, name := range stale {
+		_ = m.Reclaim(name)
+	}
+}
+
+// Close stops the background eviction loop. It does not reclaim any
+// remaining monitoring groups; call Reclaim explicitly for those still in use.
+func (m *MonGroupManager) Close() error {
+	close(m.stop)
+	<-m.done
+	return nil
+}
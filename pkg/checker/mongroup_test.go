@@ -0,0 +1,95 @@
+package checker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// prepareMonGroupFixture creates a fake resctrl mountpoint and cgroup
+// directory, including the tasks file resctrl itself would normally create
+// when a monitoring group directory is made.
+func prepareMonGroupFixture(t *testing.T, mountPoint, name string, procs string) string {
+	t.Helper()
+	groupPath := filepath.Join(mountPoint, "mon_groups", name)
+	if err := os.MkdirAll(groupPath, 0755); err != nil {
+		t.Fatalf("MkdirAll groupPath: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(groupPath, "tasks"), nil, 0644); err != nil {
+		t.Fatalf("WriteFile tasks: %v", err)
+	}
+
+	cgroupPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cgroupPath, "cgroup.procs"), []byte(procs), 0644); err != nil {
+		t.Fatalf("WriteFile cgroup.procs: %v", err)
+	}
+	return cgroupPath
+}
+
+func TestMonGroupManagerAllocateAndReclaim(t *testing.T) {
+	mountPoint := t.TempDir()
+	cgroupPath := prepareMonGroupFixture(t, mountPoint, "test-group", "123\n456\n")
+
+	m := NewMonGroupManager(mountPoint, Capabilities{NumRMIDs: 8})
+	defer m.Close()
+
+	group, err := m.Allocate("test-group", cgroupPath)
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+
+	tasksData, err := os.ReadFile(filepath.Join(group.Path, "tasks"))
+	if err != nil {
+		t.Fatalf("reading tasks file: %v", err)
+	}
+	if string(tasksData) != "123456" {
+		t.Errorf("expected tasks file to contain written pids, got %q", tasksData)
+	}
+
+	if err := m.Reclaim("test-group"); err != nil {
+		t.Fatalf("Reclaim: %v", err)
+	}
+	if _, err := os.Stat(group.Path); !os.IsNotExist(err) {
+		t.Errorf("expected group directory to be removed after Reclaim, stat err = %v", err)
+	}
+}
+
+func TestMonGroupManagerAllocateExhaustsRMIDs(t *testing.T) {
+	mountPoint := t.TempDir()
+	m := NewMonGroupManager(mountPoint, Capabilities{NumRMIDs: 1})
+	defer m.Close()
+
+	cgroupPath1 := prepareMonGroupFixture(t, mountPoint, "group-1", "")
+	if _, err := m.Allocate("group-1", cgroupPath1); err != nil {
+		t.Fatalf("Allocate group-1: %v", err)
+	}
+
+	cgroupPath2 := prepareMonGroupFixture(t, mountPoint, "group-2", "")
+	if _, err := m.Allocate("group-2", cgroupPath2); !errors.Is(err, ErrNoRMIDsAvailable) {
+		t.Fatalf("expected ErrNoRMIDsAvailable, got %v", err)
+	}
+}
+
+func TestMonGroupManagerEvictsRemovedCgroups(t *testing.T) {
+	mountPoint := t.TempDir()
+	cgroupPath := prepareMonGroupFixture(t, mountPoint, "test-group", "")
+
+	m := NewMonGroupManager(mountPoint, Capabilities{NumRMIDs: 8})
+	defer m.Close()
+
+	group, err := m.Allocate("test-group", cgroupPath)
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+
+	if err := os.RemoveAll(cgroupPath); err != nil {
+		t.Fatalf("RemoveAll cgroupPath: %v", err)
+	}
+
+	m.evictRemovedCgroups()
+
+	if _, err := os.Stat(group.Path); !os.IsNotExist(err) {
+		t.Errorf("expected monitoring group to be evicted once its cgroup disappeared, stat err = %v", err)
+	}
+}
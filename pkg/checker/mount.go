@@ -0,0 +1,273 @@
+package checker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultMountPoint is where resctrl is mounted if no mount already exists.
+const defaultMountPoint = "/sys/fs/resctrl"
+
+// ResctrlOptions selects which optional resctrl mount features to negotiate.
+// All are off by default, matching the kernel's own defaults.
+type ResctrlOptions struct {
+	// MBAMBps requests the mba_MBps mount option (MBA controlled in MBps
+	// rather than abstract throttling percentages).
+	MBAMBps bool
+	// CDP requests the cdp mount option (separate L3 code/data allocation).
+	CDP bool
+	// CDPL2 requests the cdpl2 mount option (separate L2 code/data allocation).
+	CDPL2 bool
+}
+
+// mountOptions returns the requested options as a sorted, comma-separated
+// mount(8) option string, e.g. "cdp,mba_MBps". Empty if none are requested.
+func (o ResctrlOptions) mountOptions() string {
+	var opts []string
+	if o.MBAMBps {
+		opts = append(opts, "mba_MBps")
+	}
+	if o.CDP {
+		opts = append(opts, "cdp")
+	}
+	if o.CDPL2 {
+		opts = append(opts, "cdpl2")
+	}
+	sort.Strings(opts)
+	return strings.Join(opts, ",")
+}
+
+// satisfiedBy reports whether superOptions (as reported in mountinfo for an
+// existing mount) already includes every option o requests.
+func (o ResctrlOptions) satisfiedBy(superOptions string) bool {
+	have := make(map[string]bool)
+	for _, opt := range strings.Split(superOptions, ",") {
+		have[opt] = true
+	}
+	if o.MBAMBps && !have["mba_MBps"] {
+		return false
+	}
+	if o.CDP && !have["cdp"] {
+		return false
+	}
+	if o.CDPL2 && !have["cdpl2"] {
+		return false
+	}
+	return true
+}
+
+// mountEntry is one parsed line of /proc/self/mountinfo.
+type mountEntry struct {
+	mountPoint   string
+	mountOptions string
+	fsType       string
+	superOptions string
+}
+
+// parseMountInfo parses the mountinfo format documented in proc(5), returning
+// every mount entry found. It does not split naively on spaces: mountpoints
+// and other path fields are \NNN-octal-escaped by the kernel when they
+// contain spaces, tabs, newlines, or backslashes, and the fields preceding
+// the " - " separator are of variable length (the "optional fields").
+func parseMountInfo(r io.Reader) ([]mountEntry, error) {
+	var entries []mountEntry
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+
+		sepIdx := strings.Index(line, " - ")
+		if sepIdx < 0 {
+			return nil, fmt.Errorf("checker: malformed mountinfo line (no separator): %q", line)
+		}
+
+		preFields := strings.Fields(line[:sepIdx])
+		postFields := strings.Fields(line[sepIdx+3:])
+		if len(preFields) < 6 {
+			return nil, fmt.Errorf("checker: malformed mountinfo line (too few pre-separator fields): %q", line)
+		}
+		if len(postFields) < 2 {
+			return nil, fmt.Errorf("checker: malformed mountinfo line (too few post-separator fields): %q", line)
+		}
+
+		entry := mountEntry{
+			mountPoint:   unescapeMountField(preFields[4]),
+			mountOptions: preFields[5],
+			fsType:       postFields[0],
+		}
+		if len(postFields) >= 3 {
+			entry.superOptions = postFields[2]
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// unescapeMountField decodes the kernel's \NNN octal escapes (used for
+// space, tab, newline, and backslash) in a mountinfo path field.
+func unescapeMountField(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// findResctrlMounts returns every resctrl mount currently visible to this
+// process, in mountinfo order.
+func findResctrlMounts() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := parseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var resctrl []mountEntry
+	for _, e := range entries {
+		if e.fsType == "resctrl" {
+			resctrl = append(resctrl, e)
+		}
+	}
+	return resctrl, nil
+}
+
+// EnsureMounted finds or creates a resctrl mount that satisfies opts,
+// remounting an existing mount in place if its current options fall short,
+// and returns the mountpoint in use.
+func EnsureMounted(opts ResctrlOptions) (string, error) {
+	mounts, err := findResctrlMounts()
+	if err != nil {
+		return "", fmt.Errorf("reading /proc/self/mountinfo: %w", err)
+	}
+
+	for _, m := range mounts {
+		if opts.satisfiedBy(m.superOptions) {
+			return m.mountPoint, nil
+		}
+	}
+
+	want := opts.mountOptions()
+
+	if len(mounts) > 0 {
+		// Already mounted, just missing the options we need: remount in place
+		// rather than mounting a second instance.
+		mountPoint := mounts[0].mountPoint
+		if err := remountResctrl(mountPoint, want); err != nil {
+			return "", fmt.Errorf("remounting resctrl at %s with options %q: %w", mountPoint, want, err)
+		}
+		return mountPoint, nil
+	}
+
+	if err := os.MkdirAll(defaultMountPoint, 0755); err != nil {
+		return "", fmt.Errorf("creating %s: %w", defaultMountPoint, err)
+	}
+	if err := mountResctrl(defaultMountPoint, want); err != nil {
+		return "", fmt.Errorf("mounting resctrl at %s with options %q: %w", defaultMountPoint, want, err)
+	}
+	return defaultMountPoint, nil
+}
+
+func mountResctrl(mountPoint, options string) error {
+	args := []string{"-t", "resctrl", "resctrl"}
+	if options != "" {
+		args = append(args, "-o", options)
+	}
+	args = append(args, mountPoint)
+	return exec.Command("mount", args...).Run()
+}
+
+func remountResctrl(mountPoint, options string) error {
+	remountOpts := "remount"
+	if options != "" {
+		remountOpts = "remount," + options
+	}
+	return exec.Command("mount", "-o", remountOpts, mountPoint).Run()
+}
+
+// Capabilities describes the RDT monitoring features the kernel's resctrl
+// mount actually exposes, as read from info/L3_MON.
+type Capabilities struct {
+	// CMT is true if L3 cache occupancy monitoring (llc_occupancy) is available.
+	CMT bool
+	// MBM is true if memory bandwidth monitoring (mbm_total_bytes/mbm_local_bytes) is available.
+	MBM bool
+	// MonFeatures lists every feature name read from info/L3_MON/mon_features verbatim.
+	MonFeatures []string
+	// NumRMIDs is the number of monitoring IDs the hardware supports, read
+	// from info/L3_MON/num_rmids.
+	NumRMIDs int
+}
+
+// DiscoverCapabilities reads mountPoint's info/L3_MON directory to determine
+// which monitoring features are available and how many RMIDs the hardware
+// supports. It returns ErrKernelMissingCMT if L3_MON doesn't exist at all
+// (the kernel/CPU doesn't support cache monitoring technology).
+func DiscoverCapabilities(mountPoint string) (Capabilities, error) {
+	monDir := filepath.Join(mountPoint, "info", "L3_MON")
+
+	featuresRaw, err := os.ReadFile(filepath.Join(monDir, "mon_features"))
+	if os.IsNotExist(err) {
+		return Capabilities{}, ErrKernelMissingCMT
+	}
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("reading %s: %w", filepath.Join(monDir, "mon_features"), err)
+	}
+
+	var caps Capabilities
+	for _, line := range strings.Split(strings.TrimSpace(string(featuresRaw)), "\n") {
+		feature := strings.TrimSpace(line)
+		if feature == "" {
+			continue
+		}
+		caps.MonFeatures = append(caps.MonFeatures, feature)
+		switch feature {
+		case "llc_occupancy":
+			caps.CMT = true
+		case "mbm_total_bytes", "mbm_local_bytes":
+			caps.MBM = true
+		}
+	}
+
+	numRMIDsRaw, err := os.ReadFile(filepath.Join(monDir, "num_rmids"))
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("reading %s: %w", filepath.Join(monDir, "num_rmids"), err)
+	}
+	numRMIDs, err := strconv.Atoi(strings.TrimSpace(string(numRMIDsRaw)))
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("parsing num_rmids: %w", err)
+	}
+	caps.NumRMIDs = numRMIDs
+
+	return caps, nil
+}
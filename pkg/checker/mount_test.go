@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	data := strings.Join([]string{
+		`36 35 98:0 / /sys/fs/resctrl rw,relatime shared:1 - resctrl resctrl rw,cdp`,
+		`37 35 0:31 / /sys/fs/cgroup rw,relatime - cgroup2 cgroup2 rw`,
+		`38 35 0:32 / /mnt/with\040space rw - ext4 /dev/sda1 rw,noatime`,
+	}, "\n") + "\n"
+
+	entries, err := parseMountInfo(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseMountInfo: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].fsType != "resctrl" || entries[0].mountPoint != "/sys/fs/resctrl" || entries[0].superOptions != "rw,cdp" {
+		t.Errorf("unexpected resctrl entry: %+v", entries[0])
+	}
+	if entries[2].mountPoint != "/mnt/with space" {
+		t.Errorf("expected escaped space to be decoded, got %q", entries[2].mountPoint)
+	}
+}
+
+func TestParseMountInfoRejectsMalformedLine(t *testing.T) {
+	if _, err := parseMountInfo(strings.NewReader("not a valid mountinfo line\n")); err == nil {
+		t.Fatal("expected an error for a line without ' - ' separator")
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	cases := map[string]string{
+		"/no/escapes":    "/no/escapes",
+		`/with\040space`: "/with space",
+		`/tab\011here`:   "/tab\there",
+		`/back\134slash`: `/back\slash`,
+		`/trailing\04`:   `/trailing\04`, // too short to be a valid escape
+	}
+	for in, want := range cases {
+		if got := unescapeMountField(in); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestResctrlOptionsMountOptions(t *testing.T) {
+	cases := []struct {
+		opts ResctrlOptions
+		want string
+	}{
+		{ResctrlOptions{}, ""},
+		{ResctrlOptions{MBAMBps: true}, "mba_MBps"},
+		{ResctrlOptions{CDP: true, CDPL2: true, MBAMBps: true}, "cdp,cdpl2,mba_MBps"},
+	}
+	for _, tc := range cases {
+		if got := tc.opts.mountOptions(); got != tc.want {
+			t.Errorf("mountOptions(%+v) = %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestResctrlOptionsSatisfiedBy(t *testing.T) {
+	opts := ResctrlOptions{CDP: true}
+	if opts.satisfiedBy("rw,relatime") {
+		t.Error("expected options without cdp to not satisfy a CDP request")
+	}
+	if !opts.satisfiedBy("rw,cdp") {
+		t.Error("expected options with cdp to satisfy a CDP request")
+	}
+}
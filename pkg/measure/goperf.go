@@ -0,0 +1,67 @@
+package measure
+
+import "github.com/elastic/go-perf"
+
+// GoPerfMeasurer measures instructions and cycles in-process via
+// elastic/go-perf. It measures the calling thread, so callers that need its
+// counts to line up with PerfCmdMeasurer's should pin the calling goroutine
+// to its OS thread first (runtime.LockOSThread) before calling Start.
+type GoPerfMeasurer struct {
+	event *perf.Event
+}
+
+// NewGoPerfMeasurer creates a GoPerfMeasurer.
+func NewGoPerfMeasurer() *GoPerfMeasurer {
+	return &GoPerfMeasurer{}
+}
+
+// Events implements Measurer.
+func (m *GoPerfMeasurer) Events() []Event {
+	return []Event{EventInstructions, EventCPUCycles}
+}
+
+// Start implements Measurer.
+func (m *GoPerfMeasurer) Start() error {
+	group := perf.Group{
+		CountFormat: perf.CountFormat{
+			Running: true,
+		},
+	}
+	group.Add(perf.Instructions, perf.CPUCycles)
+
+	event, err := group.Open(perf.CallingThread, perf.AnyCPU)
+	if err != nil {
+		return err
+	}
+	if err := event.Reset(); err != nil {
+		event.Close()
+		return err
+	}
+	if err := event.Enable(); err != nil {
+		event.Close()
+		return err
+	}
+
+	m.event = event
+	return nil
+}
+
+// End implements Measurer.
+func (m *GoPerfMeasurer) End() (*Output, error) {
+	if err := m.event.Disable(); err != nil {
+		return nil, err
+	}
+
+	gc, err := m.event.ReadGroupCount()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.event.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Output{Values: map[Event]float64{
+		EventInstructions: float64(gc.Values[0].Value),
+		EventCPUCycles:    float64(gc.Values[1].Value),
+	}}, nil
+}
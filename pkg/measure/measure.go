@@ -0,0 +1,51 @@
+// Package measure defines a common interface for observing hardware
+// performance counters (instructions, cycles, ...) across process-internal
+// and external measurement backends.
+package measure
+
+import "fmt"
+
+// Event identifies a hardware performance counter a Measurer can observe.
+type Event int
+
+const (
+	// EventInstructions counts retired instructions.
+	EventInstructions Event = iota
+	// EventCPUCycles counts CPU cycles.
+	EventCPUCycles
+)
+
+// String returns the perf event name used in `perf stat` output.
+func (e Event) String() string {
+	switch e {
+	case EventInstructions:
+		return "instructions"
+	case EventCPUCycles:
+		return "cycles"
+	default:
+		return fmt.Sprintf("measure.Event(%d)", int(e))
+	}
+}
+
+// Output holds the counter values a Measurer observed between Start and
+// End, keyed by Event.
+type Output struct {
+	Values map[Event]float64
+}
+
+// CPI returns cycles per instruction.
+func (o Output) CPI() float64 {
+	return o.Values[EventCPUCycles] / o.Values[EventInstructions]
+}
+
+// Measurer observes a fixed set of Events over the window between Start and
+// End. The caller runs whatever workload it wants measured in between the
+// two calls.
+type Measurer interface {
+	// Start begins counting.
+	Start() error
+	// End stops counting and returns the accumulated counts.
+	End() (*Output, error)
+	// Events returns the set of events this Measurer reports in Output.
+	Events() []Event
+}
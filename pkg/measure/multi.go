@@ -0,0 +1,91 @@
+package measure
+
+import "fmt"
+
+// MultiMeasurer runs several Measurers over the same workload window and
+// cross-checks that they agree on shared events within epsilon (a relative
+// tolerance, e.g. 0.15 for 15%), generalizing the manual comparison
+// TestGoPerfVsPerfCmd used to do by hand.
+type MultiMeasurer struct {
+	measurers []Measurer
+	epsilon   float64
+}
+
+// NewMultiMeasurer creates a MultiMeasurer that runs measurers together and
+// compares their results within the given relative epsilon.
+func NewMultiMeasurer(epsilon float64, measurers ...Measurer) *MultiMeasurer {
+	return &MultiMeasurer{measurers: measurers, epsilon: epsilon}
+}
+
+// Start starts every underlying Measurer. If any fails, Start returns the
+// first error without starting the remaining measurers.
+func (m *MultiMeasurer) Start() error {
+	for i, measurer := range m.measurers {
+		if err := measurer.Start(); err != nil {
+			return fmt.Errorf("start measurer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// End ends every underlying Measurer and returns their outputs in the same
+// order they were passed to NewMultiMeasurer.
+func (m *MultiMeasurer) End() ([]*Output, error) {
+	outputs := make([]*Output, len(m.measurers))
+	for i, measurer := range m.measurers {
+		out, err := measurer.End()
+		if err != nil {
+			return nil, fmt.Errorf("end measurer %d: %w", i, err)
+		}
+		outputs[i] = out
+	}
+	return outputs, nil
+}
+
+// Verify ends every underlying Measurer, then compares every pair of
+// outputs on every event they both report; two values agree if they're
+// within the configured relative epsilon of each other. It returns the
+// outputs (see End) and a non-nil error describing the first disagreement
+// found, if any.
+func (m *MultiMeasurer) Verify() ([]*Output, error) {
+	outputs, err := m.End()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(outputs); i++ {
+		for j := i + 1; j < len(outputs); j++ {
+			for _, event := range m.measurers[i].Events() {
+				a, aok := outputs[i].Values[event]
+				b, bok := outputs[j].Values[event]
+				if !aok || !bok {
+					continue
+				}
+				if !withinEpsilon(a, b, m.epsilon) {
+					return outputs, fmt.Errorf("measurer %d and %d disagree on %s: %v vs %v (epsilon %v)",
+						i, j, event, a, b, m.epsilon)
+				}
+			}
+		}
+	}
+
+	return outputs, nil
+}
+
+func withinEpsilon(a, b, epsilon float64) bool {
+	if a == b {
+		return true
+	}
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	largest := a
+	if b > largest {
+		largest = b
+	}
+	if largest < 0 {
+		largest = -largest
+	}
+	return diff <= largest*epsilon
+}
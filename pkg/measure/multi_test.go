@@ -0,0 +1,38 @@
+package measure
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func heavyWorkload() {
+	seedStr := "1sAMsDJGtS3zNrK6MfeysFvUYOzlHqtj"
+	hashBytes := sha256.Sum256([]byte(seedStr))
+	for i := 0; i < 999999; i++ {
+		hash := base64.StdEncoding.EncodeToString(hashBytes[:])
+		hashBytes = sha256.Sum256([]byte(hash))
+	}
+}
+
+func TestGoPerfVsPerfCmdVsCounter(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	goperf := NewGoPerfMeasurer()
+	perfCmd := NewPerfCmdMeasurer(os.Getpid())
+	counter := NewCounterMeasurer(CallingThread, AnyCPU)
+	defer counter.Close()
+
+	multi := NewMultiMeasurer(0.15, goperf, perfCmd, counter)
+	require.NoError(t, multi.Start())
+
+	heavyWorkload()
+
+	_, err := multi.Verify()
+	require.NoError(t, err)
+}
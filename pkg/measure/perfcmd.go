@@ -0,0 +1,96 @@
+package measure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// PerfCmdMeasurer measures instructions and cycles for a process by
+// shelling out to `perf stat -j -p <pid>`.
+type PerfCmdMeasurer struct {
+	cmd    *exec.Cmd
+	output *bytes.Buffer
+}
+
+// NewPerfCmdMeasurer creates a PerfCmdMeasurer that attaches to pid.
+func NewPerfCmdMeasurer(pid int) *PerfCmdMeasurer {
+	var buf bytes.Buffer
+
+	cmd := exec.Command("perf", "stat", "-j", "-e", "instructions,cycles", "-p", strconv.Itoa(pid))
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+
+	return &PerfCmdMeasurer{
+		cmd:    cmd,
+		output: &buf,
+	}
+}
+
+// Events implements Measurer.
+func (m *PerfCmdMeasurer) Events() []Event {
+	return []Event{EventInstructions, EventCPUCycles}
+}
+
+// Start implements Measurer.
+func (m *PerfCmdMeasurer) Start() error {
+	return m.cmd.Start()
+}
+
+// End implements Measurer.
+func (m *PerfCmdMeasurer) End() (*Output, error) {
+	// Send Ctrl-C to the perf process...
+	if err := m.cmd.Process.Signal(os.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// ... and wait for it to finish writing to stdout/stderr buffers and exit.
+	if err := m.cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+	}
+
+	return parsePerfCmdOutput(m.output)
+}
+
+type perfCmdLine struct {
+	Event string `json:"event"`
+	Count string `json:"counter-value"`
+}
+
+func parsePerfCmdOutput(r io.Reader) (*Output, error) {
+	scanner := bufio.NewScanner(r)
+	out := &Output{Values: make(map[Event]float64)}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		var data perfCmdLine
+		if err := json.Unmarshal([]byte(line), &data); err != nil {
+			return nil, fmt.Errorf("parse perf cmd output %q: %w", line, err)
+		}
+
+		count, err := strconv.ParseFloat(data.Count, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse perf cmd counter value %q: %w", data.Count, err)
+		}
+
+		switch data.Event {
+		case EventInstructions.String():
+			out.Values[EventInstructions] = count
+		case EventCPUCycles.String():
+			out.Values[EventCPUCycles] = count
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan perf cmd output: %w", err)
+	}
+
+	return out, nil
+}
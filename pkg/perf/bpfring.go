@@ -0,0 +1,236 @@
+package perf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ErrFlushed is returned when a read is interrupted because the ring was
+	// woken up without a new record being available (e.g. the watermark was
+	// reached by a discarded record, or the kernel flushed the ring on close).
+	ErrFlushed = fmt.Errorf("ring buffer flushed")
+)
+
+const (
+	// bpfRingbufBusyBit marks a record that the BPF producer has reserved but not yet committed
+	bpfRingbufBusyBit = uint32(1) << 31
+	// bpfRingbufDiscardBit marks a record the BPF producer committed but asked consumers to skip
+	bpfRingbufDiscardBit = uint32(1) << 30
+	// bpfRingbufHdrSize is the size in bytes of the length header prefixing every record
+	bpfRingbufHdrSize = 8
+)
+
+// BpfRingStorage implements RingStorage on top of a BPF_MAP_TYPE_RINGBUF map.
+//
+// Unlike the per-CPU perf event array, a BPF ringbuf map is a single
+// multi-producer/single-consumer ring shared by every CPU. The kernel
+// exposes it as two separate mmap regions: a writable page holding the
+// consumer position, and a read-only region holding the producer position
+// followed by the data area, mapped twice back to back so that a record
+// never needs to be split by the reader.
+//
+// This is a standalone backend: no reader loop in this tree selects between
+// it and the per-CPU perf event array backend yet. pkg/perf_ebpf.RingBufMapReader
+// covers the BPF_MAP_TYPE_RINGBUF case today, but does so by wrapping
+// cilium/ebpf's own ringbuf.Reader directly rather than going through
+// BpfRingStorage/PerfRing.
+type BpfRingStorage struct {
+	consumer []byte // 1 page, read-write: consumer position
+	producer []byte // 1 page + 2*dataSize, read-only: producer position + mirrored data
+	dataSize uint64
+	pageSize uint64
+	mapFD    int
+}
+
+// NewBpfRingStorage maps the consumer/producer pages and data region of a
+// BPF_MAP_TYPE_RINGBUF map identified by mapFD. nPages is the number of data
+// pages backing the ring and must match the map's max_entries/page_size.
+// watermark is currently unused for ringbuf maps (the kernel wakes up
+// consumers based on BPF_RB_NO_WAKEUP/BPF_RB_FORCE_WAKEUP passed by the
+// producer) but is accepted for parity with NewMmapRingStorage and future use.
+func NewBpfRingStorage(mapFD int, nPages uint32, watermark uint32) (*BpfRingStorage, error) {
+	if mapFD < 0 {
+		return nil, fmt.Errorf("invalid map file descriptor: %d", mapFD)
+	}
+
+	pageSize := uint64(unix.Getpagesize())
+	dataSize := uint64(nPages) * pageSize
+	if dataSize == 0 || (dataSize&(dataSize-1)) != 0 {
+		return nil, ErrInvalidBufferLength
+	}
+
+	consumer, err := unix.Mmap(mapFD, 0, int(pageSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap consumer page failed: %w", err)
+	}
+
+	producer, err := unix.Mmap(mapFD, int64(pageSize), int(pageSize+2*dataSize), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		unix.Munmap(consumer)
+		return nil, fmt.Errorf("mmap producer page and data failed: %w", err)
+	}
+
+	return &BpfRingStorage{
+		consumer: consumer,
+		producer: producer,
+		dataSize: dataSize,
+		pageSize: pageSize,
+		mapFD:    mapFD,
+	}, nil
+}
+
+// Data returns the mirrored data region (excludes the consumer and producer position pages)
+func (s *BpfRingStorage) Data() []byte { return s.producer[s.pageSize:] }
+
+// NumDataPages returns the number of data pages in the ring buffer
+func (s *BpfRingStorage) NumDataPages() uint32 { return uint32(s.dataSize / s.pageSize) }
+
+// PageSize returns the system page size
+func (s *BpfRingStorage) PageSize() uint64 { return s.pageSize }
+
+// FileDescriptor returns the underlying BPF map file descriptor. BpfRingStorage does
+// not own it: the caller is responsible for closing the map.
+func (s *BpfRingStorage) FileDescriptor() int { return s.mapFD }
+
+// Close unmaps the consumer and producer/data regions. It does not close the map fd.
+func (s *BpfRingStorage) Close() error {
+	if s.consumer != nil {
+		if err := unix.Munmap(s.consumer); err != nil {
+			return fmt.Errorf("munmap consumer page failed: %w", err)
+		}
+		s.consumer = nil
+	}
+	if s.producer != nil {
+		if err := unix.Munmap(s.producer); err != nil {
+			return fmt.Errorf("munmap producer page and data failed: %w", err)
+		}
+		s.producer = nil
+	}
+	return nil
+}
+
+// BpfRing provides single-reader access to the records published to a BPF ringbuf map.
+//
+// It mirrors the StartReadBatch/Peek*/Pop/FinishReadBatch shape of PerfRing so the two
+// backends can be driven by similar consumer loops, but it is not a drop-in replacement:
+// a ringbuf has one shared ring rather than one ring per CPU, and records carry no CPU
+// or perf record type, only a length and a discard flag.
+type BpfRing struct {
+	storage *BpfRingStorage
+	mask    uint64
+	cons    uint64 // consumer position, advances as records are popped
+	prod    uint64 // producer position snapshotted at StartReadBatch
+
+	// lostRecords counts records the producer marked discarded (BPF_RINGBUF_DISCARD_BIT),
+	// which is the closest ringbuf equivalent to a perf event array's lost-sample counter.
+	lostRecords uint64
+}
+
+// NewBpfRing creates a BpfRing reading from storage.
+func NewBpfRing(storage *BpfRingStorage) (*BpfRing, error) {
+	if storage == nil {
+		return nil, ErrNilBuffer
+	}
+	return &BpfRing{
+		storage: storage,
+		mask:    storage.dataSize - 1,
+		cons:    atomic.LoadUint64((*uint64)(unsafe.Pointer(&storage.consumer[0]))),
+	}, nil
+}
+
+// StartReadBatch snapshots the current producer position
+func (r *BpfRing) StartReadBatch() {
+	r.prod = atomic.LoadUint64((*uint64)(unsafe.Pointer(&r.storage.producer[0])))
+}
+
+// recordHeader returns the raw length field of the record at the current consumer position
+func (r *BpfRing) recordHeader() uint32 {
+	data := r.storage.Data()
+	pos := r.cons & r.mask
+	return atomic.LoadUint32((*uint32)(unsafe.Pointer(&data[pos])))
+}
+
+// PeekSize returns the size of the next available record, skipping over (and counting)
+// any discarded records. It returns ErrBufferEmpty when no committed record is available,
+// and ErrFlushed when the only records remaining up to the producer position are discarded.
+func (r *BpfRing) PeekSize() (int, error) {
+	flushed := false
+	for r.cons != r.prod {
+		hdr := r.recordHeader()
+		if hdr&bpfRingbufBusyBit != 0 {
+			// producer reserved but hasn't committed yet
+			return 0, ErrBufferEmpty
+		}
+
+		length := hdr &^ (bpfRingbufBusyBit | bpfRingbufDiscardBit)
+		consumed := roundupRecordLen(length)
+
+		if hdr&bpfRingbufDiscardBit != 0 {
+			atomic.AddUint64(&r.lostRecords, 1)
+			r.cons += consumed
+			atomic.StoreUint64((*uint64)(unsafe.Pointer(&r.storage.consumer[0])), r.cons)
+			flushed = true
+			continue
+		}
+
+		return int(length), nil
+	}
+
+	if flushed {
+		return 0, ErrFlushed
+	}
+	return 0, ErrBufferEmpty
+}
+
+// roundupRecordLen returns the total number of bytes (header + payload, 8-byte aligned)
+// consumed by a record whose payload length is len.
+func roundupRecordLen(length uint32) uint64 {
+	total := uint64(length) + bpfRingbufHdrSize
+	return (total + 7) &^ 7
+}
+
+// PeekCopy copies up to len(buf) bytes of the current record's payload into buf
+func (r *BpfRing) PeekCopy(buf []byte, offset uint16) error {
+	size, err := r.PeekSize()
+	if err != nil {
+		return err
+	}
+	if len(buf)+int(offset) > size {
+		return ErrSizeExceeded
+	}
+
+	data := r.storage.Data()
+	start := (r.cons & r.mask) + bpfRingbufHdrSize + uint64(offset)
+	copy(buf, data[start:start+uint64(len(buf))])
+	return nil
+}
+
+// Pop consumes the current record
+func (r *BpfRing) Pop() error {
+	size, err := r.PeekSize()
+	if err != nil {
+		return err
+	}
+
+	r.cons += roundupRecordLen(uint32(size))
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&r.storage.consumer[0])), r.cons)
+	return nil
+}
+
+// FinishReadBatch is a no-op kept for symmetry with PerfRing: the consumer position is
+// published after every Pop so downstream producers see space freed as soon as possible.
+func (r *BpfRing) FinishReadBatch() {}
+
+// LostRecords returns the running count of records the BPF producer discarded
+func (r *BpfRing) LostRecords() uint64 {
+	return atomic.LoadUint64(&r.lostRecords)
+}
+
+// BytesRemaining returns the number of bytes available to read, including discarded records
+func (r *BpfRing) BytesRemaining() uint32 {
+	return uint32(r.prod - r.cons)
+}
@@ -0,0 +1,311 @@
+package perf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileRingStorageMagic identifies a file as FileRingStorage's on-disk format.
+const fileRingStorageMagic = uint32(0x50524653) // "PRFS"
+
+// File format versions for FileRingStorage.
+const (
+	// FileRingStorageV1 stores records exactly as the in-memory mmap ring
+	// does, so any record must fit within a single data page's worth of
+	// wraparound space (matching the existing PerfRing/Write constraints).
+	FileRingStorageV1 = uint8(1)
+	// FileRingStorageV2 additionally supports records larger than the ring
+	// can hold, by spilling them to an append-only area past the data pages
+	// (see WriteSpill/ReadSpill) framed with a 4-byte length prefix.
+	FileRingStorageV2 = uint8(2)
+)
+
+// fileRingStorageHeaderSize is the fixed size of the header page written at
+// the start of a FileRingStorage file, ahead of the ring's own metadata and
+// data pages.
+const fileRingStorageHeaderSize = 4096
+
+// fileRingStorageHeader is the on-disk layout of a FileRingStorage file's
+// header page. It's read and written with encoding/binary rather than cast
+// via unsafe.Pointer, since this layout must stay stable across processes
+// and architectures reading the same captured file.
+type fileRingStorageHeader struct {
+	Magic        uint32
+	Version      uint8
+	_            [3]byte // padding to keep the following fields 8-byte aligned
+	PageSize     uint64
+	NumDataPages uint32
+	_            [4]byte // padding
+	WriterCursor uint64  // last value of the ring's Data_head published by Sync
+	ReaderCursor uint64  // last value of the ring's Data_tail published by Sync
+	SpillSize    uint64  // bytes currently appended to the V2 spill area
+}
+
+const fileRingStorageHeaderWireSize = 4 + 1 + 3 + 8 + 4 + 4 + 8 + 8 + 8
+
+func (h *fileRingStorageHeader) marshal() []byte {
+	buf := make([]byte, fileRingStorageHeaderWireSize)
+	binary.LittleEndian.PutUint32(buf[0:4], h.Magic)
+	buf[4] = h.Version
+	binary.LittleEndian.PutUint64(buf[8:16], h.PageSize)
+	binary.LittleEndian.PutUint32(buf[16:20], h.NumDataPages)
+	binary.LittleEndian.PutUint64(buf[24:32], h.WriterCursor)
+	binary.LittleEndian.PutUint64(buf[32:40], h.ReaderCursor)
+	binary.LittleEndian.PutUint64(buf[40:48], h.SpillSize)
+	return buf
+}
+
+func (h *fileRingStorageHeader) unmarshal(buf []byte) {
+	h.Magic = binary.LittleEndian.Uint32(buf[0:4])
+	h.Version = buf[4]
+	h.PageSize = binary.LittleEndian.Uint64(buf[8:16])
+	h.NumDataPages = binary.LittleEndian.Uint32(buf[16:20])
+	h.WriterCursor = binary.LittleEndian.Uint64(buf[24:32])
+	h.ReaderCursor = binary.LittleEndian.Uint64(buf[32:40])
+	h.SpillSize = binary.LittleEndian.Uint64(buf[40:48])
+}
+
+// FileRingStorage implements RingStorage by mmapping a regular file, so a
+// perf ring's contents can be persisted to disk, replayed offline, and
+// shared between processes instead of living only in a perf_event_open
+// mmap. The file starts with a fileRingStorageHeaderSize-byte header page
+// (magic, version, page size, and reader/writer cursors for resuming a
+// capture), followed by the same [metadata page][data pages...] layout
+// InitContiguous expects.
+type FileRingStorage struct {
+	file       *os.File
+	data       []byte // the full mmap, including the header page
+	nDataPages uint32
+	pageSize   uint64
+	version    uint8
+}
+
+// NewFileRingStorage creates (or truncates) the file at path, sized to hold
+// nPages data pages in the given format version, and mmaps it MAP_SHARED.
+func NewFileRingStorage(path string, nPages uint32, version uint8) (*FileRingStorage, error) {
+	if version != FileRingStorageV1 && version != FileRingStorageV2 {
+		return nil, fmt.Errorf("unsupported FileRingStorage version %d", version)
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	totalSize := fileRingStorageHeaderSize + pageSize*(1+uint64(nPages))
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %q: %w", path, err)
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			file.Close()
+		}
+	}()
+
+	if err := file.Truncate(int64(totalSize)); err != nil {
+		return nil, fmt.Errorf("failed to truncate %q: %w", path, err)
+	}
+
+	header := fileRingStorageHeader{
+		Magic:        fileRingStorageMagic,
+		Version:      version,
+		PageSize:     pageSize,
+		NumDataPages: nPages,
+	}
+	if _, err := file.WriteAt(header.marshal(), 0); err != nil {
+		return nil, fmt.Errorf("failed to write header to %q: %w", path, err)
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, int(totalSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap failed: %w", err)
+	}
+
+	storage := &FileRingStorage{
+		file:       file,
+		data:       data,
+		nDataPages: nPages,
+		pageSize:   pageSize,
+		version:    version,
+	}
+	runtime.SetFinalizer(storage, (*FileRingStorage).Close)
+	success = true
+	return storage, nil
+}
+
+// OpenFileRingStorage reopens a file previously created by
+// NewFileRingStorage, validating its magic/version and re-mmapping it at the
+// size recorded in its header.
+func OpenFileRingStorage(path string) (*FileRingStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", path, err)
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			file.Close()
+		}
+	}()
+
+	headerBuf := make([]byte, fileRingStorageHeaderWireSize)
+	if _, err := file.ReadAt(headerBuf, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header of %q: %w", path, err)
+	}
+
+	var header fileRingStorageHeader
+	header.unmarshal(headerBuf)
+
+	if header.Magic != fileRingStorageMagic {
+		return nil, fmt.Errorf("%q is not a FileRingStorage file (bad magic %#x)", path, header.Magic)
+	}
+	if header.Version != FileRingStorageV1 && header.Version != FileRingStorageV2 {
+		return nil, fmt.Errorf("%q has unsupported FileRingStorage version %d", path, header.Version)
+	}
+
+	totalSize := fileRingStorageHeaderSize + header.PageSize*(1+uint64(header.NumDataPages)) + header.SpillSize
+	data, err := unix.Mmap(int(file.Fd()), 0, int(totalSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap failed: %w", err)
+	}
+
+	storage := &FileRingStorage{
+		file:       file,
+		data:       data,
+		nDataPages: header.NumDataPages,
+		pageSize:   header.PageSize,
+		version:    header.Version,
+	}
+	runtime.SetFinalizer(storage, (*FileRingStorage).Close)
+	success = true
+	return storage, nil
+}
+
+// Data returns the ring's [metadata page][data pages...] region, i.e. the
+// mmap past FileRingStorage's own header page, matching the layout
+// InitContiguous expects.
+func (s *FileRingStorage) Data() []byte {
+	return s.data[fileRingStorageHeaderSize : fileRingStorageHeaderSize+s.pageSize*(1+uint64(s.nDataPages))]
+}
+
+func (s *FileRingStorage) NumDataPages() uint32 { return s.nDataPages }
+func (s *FileRingStorage) PageSize() uint64     { return s.pageSize }
+func (s *FileRingStorage) FileDescriptor() int  { return int(s.file.Fd()) }
+
+// Version returns the on-disk format version this storage was created or
+// opened with (FileRingStorageV1 or FileRingStorageV2).
+func (s *FileRingStorage) Version() uint8 { return s.version }
+
+// Sync persists the ring's current head/tail positions (as published in the
+// metadata page at the start of Data() by the PerfRing wrapping it) into the
+// header page, so a later
+// OpenFileRingStorage can report where the writer and reader had gotten to.
+func (s *FileRingStorage) Sync() error {
+	meta := (*PerfEventMmapPage)(unsafe.Pointer(&s.Data()[0]))
+	var header fileRingStorageHeader
+	header.unmarshal(s.data[:fileRingStorageHeaderWireSize])
+	header.WriterCursor = meta.Data_head
+	header.ReaderCursor = meta.Data_tail
+	_, err := s.file.WriteAt(header.marshal(), 0)
+	return err
+}
+
+// WriteSpill appends data to the V2 spill area, framed with a 4-byte
+// little-endian length prefix, and returns the offset (within the spill
+// area) it was written at. It's for samples too large for the ring itself
+// to hold; ReadSpill reassembles them from the returned offset. WriteSpill
+// returns an error on a V1 storage, since V1 has no spill area.
+func (s *FileRingStorage) WriteSpill(data []byte) (uint64, error) {
+	if s.version != FileRingStorageV2 {
+		return 0, fmt.Errorf("WriteSpill requires FileRingStorageV2, storage is version %d", s.version)
+	}
+
+	var header fileRingStorageHeader
+	header.unmarshal(s.data[:fileRingStorageHeaderWireSize])
+	offset := header.SpillSize
+
+	frame := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(data)))
+	copy(frame[4:], data)
+
+	newTotal := fileRingStorageHeaderSize + s.pageSize*(1+uint64(s.nDataPages)) + offset + uint64(len(frame))
+	if newTotal > uint64(len(s.data)) {
+		if err := s.grow(newTotal); err != nil {
+			return 0, err
+		}
+	}
+
+	spillBase := fileRingStorageHeaderSize + s.pageSize*(1+uint64(s.nDataPages))
+	copy(s.data[spillBase+offset:], frame)
+
+	header.SpillSize = offset + uint64(len(frame))
+	if _, err := s.file.WriteAt(header.marshal(), 0); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// ReadSpill reads back a record previously written by WriteSpill at offset.
+func (s *FileRingStorage) ReadSpill(offset uint64) ([]byte, error) {
+	if s.version != FileRingStorageV2 {
+		return nil, fmt.Errorf("ReadSpill requires FileRingStorageV2, storage is version %d", s.version)
+	}
+
+	spillBase := fileRingStorageHeaderSize + s.pageSize*(1+uint64(s.nDataPages))
+	if spillBase+offset+4 > uint64(len(s.data)) {
+		return nil, fmt.Errorf("spill offset %d out of range", offset)
+	}
+
+	size := binary.LittleEndian.Uint32(s.data[spillBase+offset : spillBase+offset+4])
+	start := spillBase + offset + 4
+	if start+uint64(size) > uint64(len(s.data)) {
+		return nil, fmt.Errorf("spill record at offset %d is truncated", offset)
+	}
+
+	out := make([]byte, size)
+	copy(out, s.data[start:start+uint64(size)])
+	return out, nil
+}
+
+// grow extends the file and re-establishes the mmap to cover newSize bytes,
+// used when the V2 spill area outgrows the current mapping.
+func (s *FileRingStorage) grow(newSize uint64) error {
+	if err := s.file.Truncate(int64(newSize)); err != nil {
+		return fmt.Errorf("failed to grow file: %w", err)
+	}
+	if err := unix.Munmap(s.data); err != nil {
+		return fmt.Errorf("munmap failed while growing: %w", err)
+	}
+	data, err := unix.Mmap(int(s.file.Fd()), 0, int(newSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mmap failed while growing: %w", err)
+	}
+	s.data = data
+	return nil
+}
+
+// Close unmaps the file and closes its file descriptor.
+func (s *FileRingStorage) Close() error {
+	if s.data != nil {
+		if err := unix.Munmap(s.data); err != nil {
+			return fmt.Errorf("munmap failed: %w", err)
+		}
+		s.data = nil
+	}
+
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return fmt.Errorf("close failed: %w", err)
+		}
+		s.file = nil
+	}
+
+	runtime.SetFinalizer(s, nil)
+	return nil
+}
@@ -0,0 +1,159 @@
+package perf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileRingStorageCreateAndReopen(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "ring.v1")
+	nPages := uint32(2)
+
+	storage, err := NewFileRingStorage(path, nPages, FileRingStorageV1)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+
+	if storage.NumDataPages() != nPages {
+		t.Errorf("expected %d pages, got %d", nPages, storage.NumDataPages())
+	}
+	if storage.Version() != FileRingStorageV1 {
+		t.Errorf("expected version %d, got %d", FileRingStorageV1, storage.Version())
+	}
+
+	expectedSize := storage.PageSize() * (1 + uint64(nPages))
+	if uint64(len(storage.Data())) != expectedSize {
+		t.Errorf("expected data size %d, got %d", expectedSize, len(storage.Data()))
+	}
+	if fd := storage.FileDescriptor(); fd < 0 {
+		t.Errorf("expected valid file descriptor, got %d", fd)
+	}
+
+	// Use the storage as a real ring, write an event, and persist cursors.
+	ring, err := InitContiguous(storage.Data(), nPages, storage.PageSize())
+	if err != nil {
+		t.Fatalf("failed to init ring: %v", err)
+	}
+	ring.StartWriteBatch()
+	if _, err := ring.Write([]byte("hello"), 1); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	if err := storage.Sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	reopened, err := OpenFileRingStorage(path)
+	if err != nil {
+		t.Fatalf("failed to reopen file storage: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.NumDataPages() != nPages {
+		t.Errorf("expected %d pages after reopen, got %d", nPages, reopened.NumDataPages())
+	}
+	if reopened.Version() != FileRingStorageV1 {
+		t.Errorf("expected version %d after reopen, got %d", FileRingStorageV1, reopened.Version())
+	}
+
+	reopenedRing, err := InitContiguous(reopened.Data(), nPages, reopened.PageSize())
+	if err != nil {
+		t.Fatalf("failed to init reopened ring: %v", err)
+	}
+	reopenedRing.StartReadBatch()
+	size, err := reopenedRing.PeekSize()
+	if err != nil {
+		t.Fatalf("failed to peek size: %v", err)
+	}
+	buf := make([]byte, size)
+	if err := reopenedRing.PeekCopy(buf, 0); err != nil {
+		t.Fatalf("failed to peek copy: %v", err)
+	}
+	if !bytes.Equal(buf[:5], []byte("hello")) {
+		t.Errorf("expected %q, got %q", "hello", buf[:5])
+	}
+}
+
+func TestOpenFileRingStorageRejectsBadMagic(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "not-a-ring")
+	if err := os.WriteFile(path, make([]byte, fileRingStorageHeaderSize), 0o644); err != nil {
+		t.Fatalf("failed to write bogus file: %v", err)
+	}
+
+	if _, err := OpenFileRingStorage(path); err == nil {
+		t.Fatal("expected OpenFileRingStorage to reject a file with no magic")
+	}
+}
+
+func TestFileRingStorageV2Spill(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "ring.v2")
+	storage, err := NewFileRingStorage(path, 1, FileRingStorageV2)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	defer storage.Close()
+
+	big := bytes.Repeat([]byte{0xab}, int(storage.PageSize())*3)
+	offset, err := storage.WriteSpill(big)
+	if err != nil {
+		t.Fatalf("failed to write spill record: %v", err)
+	}
+
+	back, err := storage.ReadSpill(offset)
+	if err != nil {
+		t.Fatalf("failed to read spill record: %v", err)
+	}
+	if !bytes.Equal(back, big) {
+		t.Errorf("spill record roundtrip mismatch: got %d bytes, want %d", len(back), len(big))
+	}
+
+	// A second record should land after the first.
+	second := []byte("small spill record")
+	offset2, err := storage.WriteSpill(second)
+	if err != nil {
+		t.Fatalf("failed to write second spill record: %v", err)
+	}
+	back2, err := storage.ReadSpill(offset2)
+	if err != nil {
+		t.Fatalf("failed to read second spill record: %v", err)
+	}
+	if !bytes.Equal(back2, second) {
+		t.Errorf("expected %q, got %q", second, back2)
+	}
+}
+
+func TestFileRingStorageV1RejectsSpill(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "ring.v1-nospill")
+	storage, err := NewFileRingStorage(path, 1, FileRingStorageV1)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	defer storage.Close()
+
+	if _, err := storage.WriteSpill([]byte("nope")); err == nil {
+		t.Error("expected WriteSpill to fail on a V1 storage")
+	}
+}
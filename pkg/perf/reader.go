@@ -3,6 +3,7 @@ package perf
 import (
 	"container/heap"
 	"errors"
+	"sort"
 	"unsafe"
 )
 
@@ -13,6 +14,12 @@ var (
 	ErrNotActive = errors.New("reader is not active")
 	// ErrAlreadyActive is returned when trying to modify a reader while it's active
 	ErrAlreadyActive = errors.New("reader is already active")
+	// ErrNoHistory is returned by Prev and SeekTimestamp when the Reader was
+	// created with NewReader instead of NewReaderWithHistory.
+	ErrNoHistory = errors.New("reader was not created with a history buffer")
+	// ErrInvalidData is returned by Prev and SeekTimestamp when the
+	// requested position has aged out of the history buffer.
+	ErrInvalidData = errors.New("requested history position is no longer available")
 )
 
 const (
@@ -56,6 +63,31 @@ type Reader struct {
 	heap   perfEntryHeap // Heap of entries sorted by timestamp
 	inHeap []bool        // Tracks whether each ring has an entry in the heap
 	active bool
+
+	// historyCap is the number of consumed events NewReaderWithHistory
+	// asked to retain; 0 means Prev/SeekTimestamp are unavailable.
+	historyCap int
+	// history is a ring of the last historyCap consumed events, indexed by
+	// seq % historyCap.
+	history []historyEntry
+	// historySeq is the number of events ever pushed to history.
+	historySeq int64
+	// historyCursor is the seq of the event Prev last returned, or -1 if
+	// the walk-back hasn't started (or was just repositioned by
+	// SeekTimestamp, which stores the seq to return next here plus one).
+	historyCursor int64
+	// popScratch is a reusable buffer Pop uses to capture a record's raw
+	// bytes for history before consuming it, so only readers with a
+	// history buffer pay for the copy.
+	popScratch []byte
+}
+
+// historyEntry is one retained consumed event.
+type historyEntry struct {
+	timestamp uint64
+	cpu       int
+	typ       uint32
+	raw       []byte
 }
 
 // NewReader creates a new reader for accessing events
@@ -65,10 +97,25 @@ func NewReader() *Reader {
 		heap: perfEntryHeap{
 			entries: make([]perfEntry, 0),
 		},
-		inHeap: make([]bool, 0),
+		inHeap:        make([]bool, 0),
+		historyCursor: -1,
 	}
 }
 
+// NewReaderWithHistory creates a Reader like NewReader that additionally
+// retains the last n consumed events, so callers can walk back over them
+// with Prev and SeekTimestamp for post-mortem inspection (e.g. "show me the
+// samples before this throttle event") without buffering everything
+// themselves.
+func NewReaderWithHistory(n int) *Reader {
+	r := NewReader()
+	if n > 0 {
+		r.historyCap = n
+		r.history = make([]historyEntry, n)
+	}
+	return r
+}
+
 // AddRing adds a ring to the collection
 func (r *Reader) AddRing(ring *PerfRing) error {
 	if r.active {
@@ -96,11 +143,15 @@ func (r *Reader) Start() error {
 		return ErrAlreadyActive
 	}
 
-	// Start read batches and initialize the heap
+	// Start read batches and initialize the heap. If a ring lost data while
+	// this reader wasn't reading it, surface that immediately rather than
+	// leaving it to the first Pop.
 	for i, ring := range r.rings {
 		ring.StartReadBatch()
 		if !r.inHeap[i] {
-			r.maintainHeapEntry(i)
+			if err := r.maintainHeapEntry(i); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -153,7 +204,80 @@ func (r *Reader) CurrentRing() (*PerfRing, error) {
 	return r.rings[entry.ringIndex], nil
 }
 
-// Pop consumes the current event and updates the heap
+// Record is a single decoded perf event, identified by the CPU (ring) it came
+// from, its perf_event_header type, and (for PERF_RECORD_SAMPLE records) its
+// timestamp.
+type Record struct {
+	// CPU is the index of the ring the record was read from. Reader assigns
+	// rings CPU indices in the order they're passed to AddRing, so this is
+	// the real CPU number only when ring i was added for CPU i. For
+	// perf_ebpf.PerfMapReader, which adds one ring per CPU in its resolved
+	// CPU set, index i belongs to CPUs()[i].
+	CPU int
+	// Type is the perf_event_header type, e.g. PERF_RECORD_SAMPLE or
+	// PERF_RECORD_LOST.
+	Type uint32
+	// Timestamp is the sample timestamp for PERF_RECORD_SAMPLE records, and 0
+	// for every other record type.
+	Timestamp uint64
+	// RawSample holds the record's payload exactly as PerfRing.ReadBatch
+	// would hand it to a callback: for PERF_RECORD_SAMPLE this includes the
+	// leading 4-byte size field written by PerfRing.Write, and for every
+	// record type it may include trailing bytes from the ring's 8-byte
+	// alignment padding.
+	RawSample []byte
+}
+
+// Read decodes the next event, in timestamp order across every added ring,
+// into rec. It grows rec.RawSample only when its current capacity is too
+// small for the record, so a steady-state caller that reuses the same Record
+// across calls performs no allocations. Read consumes the record, equivalent
+// to CurrentRing+PeekCopy+Pop.
+func (r *Reader) Read(rec *Record) error {
+	if !r.active {
+		return ErrNotActive
+	}
+	if r.heap.size == 0 {
+		return ErrBufferEmpty
+	}
+
+	entry := r.heap.entries[0]
+	ring := r.rings[entry.ringIndex]
+
+	size, err := ring.PeekSize()
+	if err != nil {
+		return err
+	}
+
+	if cap(rec.RawSample) < size {
+		rec.RawSample = make([]byte, size)
+	} else {
+		rec.RawSample = rec.RawSample[:size]
+	}
+	if size > 0 {
+		if err := ring.PeekCopy(rec.RawSample, 0); err != nil {
+			return err
+		}
+	}
+
+	rec.CPU = entry.ringIndex
+	rec.Type = ring.PeekType()
+	rec.Timestamp = entry.timestamp
+
+	if r.historyCap > 0 {
+		r.pushHistory(rec.Timestamp, rec.CPU, rec.Type, rec.RawSample)
+	}
+
+	if err := ring.Pop(); err != nil {
+		return err
+	}
+	return r.maintainHeapEntry(entry.ringIndex)
+}
+
+// Pop consumes the current event and updates the heap. Pop returns
+// ErrLostData (see PerfRing.LostBytes/Resync) if the ring's producer
+// overtook the consumer; the heap entry for that ring is left untouched
+// until the caller resyncs it.
 func (r *Reader) Pop() error {
 	if !r.active {
 		return ErrNotActive
@@ -165,13 +289,125 @@ func (r *Reader) Pop() error {
 	entry := r.heap.entries[0]
 	ring := r.rings[entry.ringIndex]
 
+	if r.historyCap > 0 {
+		if size, err := ring.PeekSize(); err == nil {
+			if cap(r.popScratch) < size {
+				r.popScratch = make([]byte, size)
+			} else {
+				r.popScratch = r.popScratch[:size]
+			}
+			if size == 0 {
+				r.pushHistory(entry.timestamp, entry.ringIndex, ring.PeekType(), nil)
+			} else if err := ring.PeekCopy(r.popScratch, 0); err == nil {
+				r.pushHistory(entry.timestamp, entry.ringIndex, ring.PeekType(), r.popScratch)
+			}
+		}
+	}
+
 	if err := ring.Pop(); err != nil {
 		return err
 	}
 
 	// Update the heap entry for this ring
-	r.maintainHeapEntry(entry.ringIndex)
+	return r.maintainHeapEntry(entry.ringIndex)
+}
+
+// pushHistory records a consumed event's data in the history ring, if this
+// Reader was created with NewReaderWithHistory.
+func (r *Reader) pushHistory(timestamp uint64, cpu int, typ uint32, raw []byte) {
+	slot := int(r.historySeq % int64(r.historyCap))
+	entry := &r.history[slot]
+	entry.timestamp = timestamp
+	entry.cpu = cpu
+	entry.typ = typ
+	if cap(entry.raw) < len(raw) {
+		entry.raw = make([]byte, len(raw))
+	} else {
+		entry.raw = entry.raw[:len(raw)]
+	}
+	copy(entry.raw, raw)
+	r.historySeq++
+}
+
+// historyLen returns the number of events currently retained in history.
+func (r *Reader) historyLen() int64 {
+	if r.historySeq < int64(r.historyCap) {
+		return r.historySeq
+	}
+	return int64(r.historyCap)
+}
 
+// copyHistoryEntry decodes the history entry at seq into rec.
+func (r *Reader) copyHistoryEntry(seq int64, rec *Record) error {
+	entry := &r.history[seq%int64(r.historyCap)]
+	if cap(rec.RawSample) < len(entry.raw) {
+		rec.RawSample = make([]byte, len(entry.raw))
+	} else {
+		rec.RawSample = rec.RawSample[:len(entry.raw)]
+	}
+	copy(rec.RawSample, entry.raw)
+	rec.CPU = entry.cpu
+	rec.Type = entry.typ
+	rec.Timestamp = entry.timestamp
+	return nil
+}
+
+// Prev decodes the event immediately before the current walk-back position
+// into rec and moves that position one step further into the past. The
+// walk-back position starts just past the most recently consumed event, so
+// the first Prev returns that event itself. It is independent of Read/Pop,
+// so Prev can be called at any time to inspect history without disturbing
+// forward reading; use SeekTimestamp to jump it to a specific point first.
+//
+// Prev returns ErrNoHistory if this Reader wasn't created with
+// NewReaderWithHistory, or ErrInvalidData once the walk-back runs past the
+// oldest retained event.
+func (r *Reader) Prev(rec *Record) error {
+	if r.historyCap == 0 {
+		return ErrNoHistory
+	}
+
+	var target int64
+	if r.historyCursor < 0 {
+		target = r.historySeq - 1
+	} else {
+		target = r.historyCursor - 1
+	}
+
+	if target < 0 || r.historySeq-target > int64(r.historyCap) {
+		return ErrInvalidData
+	}
+
+	if err := r.copyHistoryEntry(target, rec); err != nil {
+		return err
+	}
+	r.historyCursor = target
+	return nil
+}
+
+// SeekTimestamp repositions the walk-back position so the next call to Prev
+// returns the most recently consumed retained event with Timestamp <= ts,
+// without itself decoding a record. It returns ErrInvalidData if every
+// retained event is newer than ts, or ErrNoHistory if this Reader wasn't
+// created with NewReaderWithHistory.
+func (r *Reader) SeekTimestamp(ts uint64) error {
+	if r.historyCap == 0 {
+		return ErrNoHistory
+	}
+
+	lo := r.historySeq - r.historyLen()
+	hi := r.historySeq
+
+	idx := lo + int64(sort.Search(int(hi-lo), func(i int) bool {
+		return r.history[(lo+int64(i))%int64(r.historyCap)].timestamp > ts
+	}))
+	target := idx - 1
+
+	if target < lo {
+		return ErrInvalidData
+	}
+
+	r.historyCursor = target + 1
 	return nil
 }
 
@@ -182,7 +418,11 @@ func (r *Reader) Pop() error {
 // - Malformed sample records (less than 8 bytes)
 // - Failed timestamp reads
 // This ensures such records are processed as soon as possible.
-func (r *Reader) maintainHeapEntry(idx int) {
+//
+// If the ring reports ErrLostData, maintainHeapEntry leaves its heap entry
+// as-is (the caller must resync the ring before the next Pop succeeds) and
+// returns the error so it reaches the caller of Start/Pop/Read.
+func (r *Reader) maintainHeapEntry(idx int) error {
 	ring := r.rings[idx]
 	inHeap := r.inHeap[idx]
 
@@ -191,13 +431,17 @@ func (r *Reader) maintainHeapEntry(idx int) {
 		panic("maintainHeapEntry was called for a ring that is not the minimum in the heap (should never happen)")
 	}
 
-	// If the ring is empty, remove its entry if it's in the heap
 	if _, err := ring.PeekSize(); err != nil {
+		if errors.Is(err, ErrLostData) {
+			return err
+		}
+		// Ring is empty (or some other terminal condition): drop its heap
+		// entry, if any.
 		if r.inHeap[idx] {
 			heap.Remove(&r.heap, 0)
 			r.inHeap[idx] = false
 		}
-		return
+		return nil
 	}
 
 	// Get the timestamp for the current entry
@@ -227,4 +471,5 @@ func (r *Reader) maintainHeapEntry(idx int) {
 		heap.Push(&r.heap, entry)
 		r.inHeap[idx] = true
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package perf
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"slices"
@@ -339,3 +340,284 @@ func TestReaderLostRecords(t *testing.T) {
 
 	reader.Finish()
 }
+
+func TestReaderRead(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	sample := make([]byte, 16)
+	binary.LittleEndian.PutUint64(sample, 100)
+	lost := []byte{1, 2, 3, 4}
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(sample, PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	if _, err := ring.Write(lost, PERF_RECORD_LOST); err != nil {
+		t.Fatalf("failed to write lost record: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	if err := reader.Start(); err != nil {
+		t.Fatalf("failed to start reader: %v", err)
+	}
+	defer reader.Finish()
+
+	var rec Record
+	if err := reader.Read(&rec); err != nil {
+		t.Fatalf("failed to read first record: %v", err)
+	}
+	if rec.CPU != 0 || rec.Type != PERF_RECORD_SAMPLE || rec.Timestamp != 100 {
+		t.Errorf("unexpected first record: %+v", rec)
+	}
+	// RawSample carries the leading 4-byte size field PerfRing.Write adds
+	// ahead of sample data, plus any 8-byte alignment padding.
+	if !bytes.HasPrefix(rec.RawSample[4:], sample) {
+		t.Errorf("expected RawSample to contain %v after the size field, got %v", sample, rec.RawSample)
+	}
+
+	// The second record is smaller than the buffer grown for the first one;
+	// Read should reuse it rather than allocate a new backing array.
+	grownCap := cap(rec.RawSample)
+	if err := reader.Read(&rec); err != nil {
+		t.Fatalf("failed to read second record: %v", err)
+	}
+	if rec.Type != PERF_RECORD_LOST || rec.Timestamp != 0 {
+		t.Errorf("unexpected second record: %+v", rec)
+	}
+	if !bytes.HasPrefix(rec.RawSample, lost) {
+		t.Errorf("expected RawSample to start with %v, got %v", lost, rec.RawSample)
+	}
+	if cap(rec.RawSample) != grownCap {
+		t.Errorf("expected Read to reuse the grown buffer, cap changed from %d to %d", grownCap, cap(rec.RawSample))
+	}
+
+	if !reader.Empty() {
+		t.Error("expected reader to be empty after reading all events")
+	}
+}
+
+func TestReaderWithoutHistoryRejectsPrev(t *testing.T) {
+	reader := NewReader()
+	var rec Record
+	if err := reader.Prev(&rec); err != ErrNoHistory {
+		t.Errorf("expected ErrNoHistory, got %v", err)
+	}
+	if err := reader.SeekTimestamp(0); err != ErrNoHistory {
+		t.Errorf("expected ErrNoHistory, got %v", err)
+	}
+}
+
+func TestReaderPrevWalksBackThroughHistory(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+
+	reader := NewReaderWithHistory(2)
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	timestamps := []uint64{100, 200, 300}
+	ring.StartWriteBatch()
+	for _, ts := range timestamps {
+		event := make([]byte, 8)
+		binary.LittleEndian.PutUint64(event, ts)
+		if _, err := ring.Write(event, PERF_RECORD_SAMPLE); err != nil {
+			t.Fatalf("failed to write event %d: %v", ts, err)
+		}
+	}
+	ring.FinishWriteBatch()
+
+	if err := reader.Start(); err != nil {
+		t.Fatalf("failed to start reader: %v", err)
+	}
+	defer reader.Finish()
+
+	var rec Record
+	for _, ts := range timestamps {
+		if err := reader.Read(&rec); err != nil {
+			t.Fatalf("failed to read event %d: %v", ts, err)
+		}
+		if rec.Timestamp != ts {
+			t.Fatalf("expected timestamp %d, got %d", ts, rec.Timestamp)
+		}
+	}
+
+	// The history buffer only holds 2 entries, so the oldest event (100) has
+	// already aged out.
+	if err := reader.Prev(&rec); err != nil {
+		t.Fatalf("failed to walk back to the newest retained event: %v", err)
+	}
+	if rec.Timestamp != 300 {
+		t.Errorf("expected timestamp 300, got %d", rec.Timestamp)
+	}
+
+	if err := reader.Prev(&rec); err != nil {
+		t.Fatalf("failed to walk back to the second-newest retained event: %v", err)
+	}
+	if rec.Timestamp != 200 {
+		t.Errorf("expected timestamp 200, got %d", rec.Timestamp)
+	}
+
+	if err := reader.Prev(&rec); err != ErrInvalidData {
+		t.Errorf("expected ErrInvalidData once the history is exhausted, got %v", err)
+	}
+}
+
+func TestReaderSeekTimestamp(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+
+	reader := NewReaderWithHistory(10)
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	timestamps := []uint64{100, 200, 300, 400}
+	ring.StartWriteBatch()
+	for _, ts := range timestamps {
+		event := make([]byte, 8)
+		binary.LittleEndian.PutUint64(event, ts)
+		if _, err := ring.Write(event, PERF_RECORD_SAMPLE); err != nil {
+			t.Fatalf("failed to write event %d: %v", ts, err)
+		}
+	}
+	ring.FinishWriteBatch()
+
+	if err := reader.Start(); err != nil {
+		t.Fatalf("failed to start reader: %v", err)
+	}
+	defer reader.Finish()
+
+	var rec Record
+	for range timestamps {
+		if err := reader.Read(&rec); err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+	}
+
+	if err := reader.SeekTimestamp(250); err != nil {
+		t.Fatalf("failed to seek: %v", err)
+	}
+	if err := reader.Prev(&rec); err != nil {
+		t.Fatalf("failed to read after seek: %v", err)
+	}
+	if rec.Timestamp != 200 {
+		t.Errorf("expected SeekTimestamp(250) to land on 200, got %d", rec.Timestamp)
+	}
+
+	if err := reader.SeekTimestamp(50); err != ErrInvalidData {
+		t.Errorf("expected ErrInvalidData seeking before the oldest retained event, got %v", err)
+	}
+}
+
+// BenchmarkReaderRead measures steady-state consumption via Read, reusing a
+// single Record across iterations.
+func BenchmarkReaderRead(b *testing.B) {
+	reader, ring := newBenchmarkReader(b)
+	sample := make([]byte, 16)
+
+	var rec Record
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		refillBenchmarkReader(b, reader, ring, sample)
+		if err := reader.Read(&rec); err != nil {
+			b.Fatalf("Read: %v", err)
+		}
+	}
+}
+
+// BenchmarkReaderCurrentRingPeekCopy measures the older CurrentRing+PeekCopy
+// path, which allocates a fresh buffer for every record, for comparison
+// against BenchmarkReaderRead.
+func BenchmarkReaderCurrentRingPeekCopy(b *testing.B) {
+	reader, ring := newBenchmarkReader(b)
+	sample := make([]byte, 16)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		refillBenchmarkReader(b, reader, ring, sample)
+
+		cur, err := reader.CurrentRing()
+		if err != nil {
+			b.Fatalf("CurrentRing: %v", err)
+		}
+		size, err := cur.PeekSize()
+		if err != nil {
+			b.Fatalf("PeekSize: %v", err)
+		}
+		buf := make([]byte, size)
+		if err := cur.PeekCopy(buf, 0); err != nil {
+			b.Fatalf("PeekCopy: %v", err)
+		}
+		if err := reader.Pop(); err != nil {
+			b.Fatalf("Pop: %v", err)
+		}
+	}
+}
+
+// newBenchmarkReader builds a single-ring Reader over a 64-page buffer.
+func newBenchmarkReader(b *testing.B) (*Reader, *PerfRing) {
+	b.Helper()
+
+	pageSize := uint64(4096)
+	nPages := uint32(64)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		b.Fatalf("failed to create ring: %v", err)
+	}
+
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		b.Fatalf("failed to add ring: %v", err)
+	}
+
+	return reader, ring
+}
+
+// refillBenchmarkReader writes one sample record if the reader is empty,
+// restarting the read batch so the benchmark never runs dry.
+func refillBenchmarkReader(b *testing.B, reader *Reader, ring *PerfRing, sample []byte) {
+	b.Helper()
+
+	if !reader.Empty() {
+		return
+	}
+
+	reader.Finish()
+	ring.StartWriteBatch()
+	if _, err := ring.Write(sample, PERF_RECORD_SAMPLE); err != nil {
+		b.Fatalf("Write: %v", err)
+	}
+	ring.FinishWriteBatch()
+	if err := reader.Start(); err != nil {
+		b.Fatalf("Start: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package perf
 
 import (
 	"errors"
+	"fmt"
 	"sync/atomic"
 	"unsafe"
 )
@@ -21,6 +22,14 @@ var (
 	ErrEmptyWrite = errors.New("cannot write empty data")
 	// ErrSizeExceeded is returned when trying to read too much data
 	ErrSizeExceeded = errors.New("requested read larger than data")
+	// ErrLostData is returned by PeekSize, Pop and ReadBatch when the
+	// producer has written more data since StartReadBatch than the ring can
+	// hold: some records between the read position and the producer's
+	// current position were overwritten before they could be consumed. The
+	// read position is left unchanged, so callers can inspect LostBytes and
+	// either call Resync to skip to the oldest surviving record, or abandon
+	// the batch.
+	ErrLostData = errors.New("perf ring buffer overrun: consumer overtaken by producer")
 )
 
 // PerfEventHeader represents the header of a perf event
@@ -42,6 +51,17 @@ type PerfRing struct {
 	head uint64
 	// Current tail position for writing
 	tail uint64
+	// scratch is a reusable buffer for stitching together records that wrap
+	// around the end of the ring, so ReadBatch only allocates when a record
+	// actually straddles the boundary, and never more than once per size.
+	scratch []byte
+	// overwrite, when set (see InitContiguousOverwrite), makes WriteOverwrite
+	// the intended write path: it advances head to make room for new events
+	// instead of returning ErrNoSpace, tracking how much it dropped in
+	// droppedBytes/droppedEvents.
+	overwrite     bool
+	droppedBytes  uint64
+	droppedEvents uint64
 }
 
 // PerfEventMmapPage represents the shared metadata page
@@ -87,14 +107,30 @@ func InitContiguous(data []byte, nPages uint32, pageSize uint64) (*PerfRing, err
 	return ring, nil
 }
 
+// InitContiguousOverwrite initializes a PerfRing the same way as
+// InitContiguous, but in overwrite mode: WriteOverwrite never returns
+// ErrNoSpace, instead advancing head to drop the oldest events so the ring
+// always holds the most recent ones. This suits on-demand snapshotting and
+// crash-dump/debug paths, where losing old context is preferable to losing
+// the latest events.
+func InitContiguousOverwrite(data []byte, nPages uint32, pageSize uint64) (*PerfRing, error) {
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	ring.overwrite = true
+	return ring, nil
+}
+
 // StartWriteBatch starts a write batch operation
 func (r *PerfRing) StartWriteBatch() {
 	// Get the current tail position from shared memory using atomic load
 	r.head = atomic.LoadUint64(&r.meta.Data_tail)
 }
 
-// Write writes data to the ring buffer with the given type
-func (r *PerfRing) Write(data []byte, eventType uint32) (int, error) {
+// prepareWrite validates data and eventType and returns the 8-byte-aligned
+// total size (header + payload) that Write/WriteOverwrite will occupy.
+func (r *PerfRing) prepareWrite(data []byte, eventType uint32) (uint32, error) {
 	if len(data) == 0 {
 		return 0, ErrEmptyWrite
 	}
@@ -111,11 +147,13 @@ func (r *PerfRing) Write(data []byte, eventType uint32) (int, error) {
 		return 0, ErrCannotFit
 	}
 
-	// Check if there's enough space
-	if r.tail+uint64(alignedLen)-r.head > r.bufMask+1 {
-		return 0, ErrNoSpace
-	}
+	return alignedLen, nil
+}
 
+// writeEvent writes data's header and payload at the current tail and
+// advances tail by alignedLen. The caller must have already ensured there's
+// enough space.
+func (r *PerfRing) writeEvent(data []byte, eventType uint32, alignedLen uint32) int {
 	// Write header
 	header := PerfEventHeader{
 		Type: eventType,
@@ -142,7 +180,71 @@ func (r *PerfRing) Write(data []byte, eventType uint32) (int, error) {
 	}
 
 	r.tail += uint64(alignedLen)
-	return int(dataPos), nil
+	return int(dataPos)
+}
+
+// Write writes data to the ring buffer with the given type
+func (r *PerfRing) Write(data []byte, eventType uint32) (int, error) {
+	alignedLen, err := r.prepareWrite(data, eventType)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if there's enough space
+	if r.tail+uint64(alignedLen)-r.head > r.bufMask+1 {
+		return 0, ErrNoSpace
+	}
+
+	return r.writeEvent(data, eventType, alignedLen), nil
+}
+
+// WriteOverwrite writes data the same way as Write, but on a ring created
+// with InitContiguousOverwrite it never fails with ErrNoSpace: instead it
+// advances head past whole events (walking each event's PerfEventHeader.Size)
+// until enough space exists, counting every dropped event and byte in
+// DroppedBytes/DroppedEvents, then publishes the new head immediately so a
+// reader's next StartReadBatch observes the gap (see StartReadBatch) before
+// performing the write. On a ring not created with InitContiguousOverwrite
+// it behaves exactly like Write.
+func (r *PerfRing) WriteOverwrite(data []byte, eventType uint32) (int, error) {
+	alignedLen, err := r.prepareWrite(data, eventType)
+	if err != nil {
+		return 0, err
+	}
+
+	if !r.overwrite {
+		if r.tail+uint64(alignedLen)-r.head > r.bufMask+1 {
+			return 0, ErrNoSpace
+		}
+		return r.writeEvent(data, eventType, alignedLen), nil
+	}
+
+	dropped := false
+	for r.tail+uint64(alignedLen)-r.head > r.bufMask+1 {
+		header := (*PerfEventHeader)(unsafe.Pointer(&r.data[r.head&r.bufMask]))
+		r.head += uint64(header.Size)
+		r.droppedBytes += uint64(header.Size)
+		r.droppedEvents++
+		dropped = true
+	}
+	if dropped {
+		atomic.StoreUint64(&r.meta.Data_tail, r.head)
+	}
+
+	return r.writeEvent(data, eventType, alignedLen), nil
+}
+
+// DroppedBytes returns the total number of bytes (headers included)
+// WriteOverwrite has dropped from the oldest end of the ring to make room
+// for newer events.
+func (r *PerfRing) DroppedBytes() uint64 {
+	return r.droppedBytes
+}
+
+// DroppedEvents returns the total number of events WriteOverwrite has
+// dropped from the oldest end of the ring to make room for newer events.
+func (r *PerfRing) DroppedEvents() uint64 {
+	return r.droppedEvents
 }
 
 // FinishWriteBatch commits the write batch
@@ -151,14 +253,54 @@ func (r *PerfRing) FinishWriteBatch() {
 	atomic.StoreUint64(&r.meta.Data_head, r.tail)
 }
 
-// StartReadBatch starts a read batch operation
-func (r *PerfRing) StartReadBatch() {
+// StartReadBatch starts a read batch operation. It always reloads the
+// producer's tail position. On an overwrite-mode ring (see
+// InitContiguousOverwrite) it also reloads head, since WriteOverwrite may
+// have advanced it between batches to drop old events out from under this
+// reader; the number of bytes skipped that way is returned (0 on a normal
+// ring, or if nothing was dropped) so callers like the aggregator can record
+// a gap in the time series instead of silently resuming mid-stream.
+func (r *PerfRing) StartReadBatch() uint64 {
 	// Get the current head position from shared memory using atomic load
 	r.tail = atomic.LoadUint64(&r.meta.Data_head)
+
+	if !r.overwrite {
+		return 0
+	}
+
+	newHead := atomic.LoadUint64(&r.meta.Data_tail)
+	skipped := newHead - r.head
+	r.head = newHead
+	return skipped
+}
+
+// LostBytes returns the number of bytes the producer has overwritten since
+// StartReadBatch, or 0 if the consumer hasn't fallen behind.
+func (r *PerfRing) LostBytes() int {
+	capacity := r.bufMask + 1
+	if r.tail-r.head <= capacity {
+		return 0
+	}
+	return int(r.tail - r.head - capacity)
+}
+
+// Resync skips the read position past any data the producer has
+// overwritten, landing on the oldest record that survived. It returns the
+// number of bytes skipped, or 0 if there was nothing to resync.
+func (r *PerfRing) Resync() int {
+	lost := r.LostBytes()
+	if lost > 0 {
+		r.head = r.tail - (r.bufMask + 1)
+	}
+	return lost
 }
 
 // PeekSize returns the size of the next event in the ring buffer
 func (r *PerfRing) PeekSize() (int, error) {
+	if lost := r.LostBytes(); lost > 0 {
+		return 0, fmt.Errorf("%w: %d bytes", ErrLostData, lost)
+	}
+
 	if r.tail == r.head {
 		return 0, ErrBufferEmpty
 	}
@@ -200,8 +342,46 @@ func (r *PerfRing) PeekCopy(buf []byte, offset uint16) error {
 	return nil
 }
 
+// PeekBytes returns zero-copy views of a region of the next event's payload,
+// starting at offset and spanning length bytes, without consuming the event.
+// It returns two slices if the region wraps around the end of the ring
+// buffer (first the tail of the buffer, then the head), or one slice and a
+// nil second slice if the region is contiguous. The returned slices point
+// directly into the mmapped data pages and are only valid until the next
+// call to Pop, ReadBatch, or FinishReadBatch on this ring; callers that need
+// the bytes to outlive that must copy them out (see PeekCopy).
+func (r *PerfRing) PeekBytes(offset, length uint16) ([]byte, []byte, error) {
+	size, err := r.PeekSize()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if int(offset)+int(length) > size {
+		return nil, nil, ErrSizeExceeded
+	}
+	if length == 0 {
+		return nil, nil, nil
+	}
+
+	startPos := (r.head + uint64(unsafe.Sizeof(PerfEventHeader{})) + uint64(offset)) & r.bufMask
+	endPos := (startPos + uint64(length) - 1) & r.bufMask
+
+	if endPos < startPos {
+		// Region wraps around buffer end
+		firstLen := uint64(len(r.data)) - startPos
+		return r.data[startPos : startPos+firstLen], r.data[:endPos+1], nil
+	}
+
+	// Region is contiguous
+	return r.data[startPos : startPos+uint64(length)], nil, nil
+}
+
 // Pop consumes the current event
 func (r *PerfRing) Pop() error {
+	if lost := r.LostBytes(); lost > 0 {
+		return fmt.Errorf("%w: %d bytes", ErrLostData, lost)
+	}
+
 	if r.tail == r.head {
 		return ErrBufferEmpty
 	}
@@ -211,6 +391,64 @@ func (r *PerfRing) Pop() error {
 	return nil
 }
 
+// ReadBatch drains every record currently available in the ring within a single
+// StartReadBatch/FinishReadBatch pair, invoking fn with the type and payload of
+// each one. The payload slice points directly into the mmapped data pages and is
+// only valid for the duration of the call; the caller must not retain it. Records
+// that wrap around the end of the ring are copied into a small reusable scratch
+// buffer first, since they can't be represented as a single contiguous slice.
+//
+// ReadBatch returns ErrLostData, without processing any records, if the
+// producer has overwritten data since StartReadBatch; call Resync and retry
+// to continue from the oldest surviving record.
+//
+// ReadBatch stops and returns the error from fn if fn returns one, without
+// consuming the record that produced it; records consumed before the error are
+// still committed via FinishReadBatch.
+func (r *PerfRing) ReadBatch(fn func(typ uint32, payload []byte) error) (int, error) {
+	r.StartReadBatch()
+
+	if lost := r.LostBytes(); lost > 0 {
+		r.FinishReadBatch()
+		return 0, fmt.Errorf("%w: %d bytes", ErrLostData, lost)
+	}
+
+	n := 0
+	for r.head != r.tail {
+		header := (*PerfEventHeader)(unsafe.Pointer(&r.data[r.head&r.bufMask]))
+		payloadLen := uint32(header.Size) - uint32(unsafe.Sizeof(PerfEventHeader{}))
+		startPos := (r.head + uint64(unsafe.Sizeof(PerfEventHeader{}))) & r.bufMask
+
+		var payload []byte
+		if payloadLen == 0 {
+			payload = nil
+		} else if startPos+uint64(payloadLen) <= uint64(len(r.data)) {
+			// Contiguous: hand back a slice directly into the data pages.
+			payload = r.data[startPos : startPos+uint64(payloadLen)]
+		} else {
+			// Wraps around the end of the ring: stitch into the scratch buffer.
+			if uint32(cap(r.scratch)) < payloadLen {
+				r.scratch = make([]byte, payloadLen)
+			}
+			payload = r.scratch[:payloadLen]
+			firstLen := uint64(len(r.data)) - startPos
+			copy(payload, r.data[startPos:])
+			copy(payload[firstLen:], r.data[:uint64(payloadLen)-firstLen])
+		}
+
+		if err := fn(header.Type, payload); err != nil {
+			r.FinishReadBatch()
+			return n, err
+		}
+
+		r.head += uint64(header.Size)
+		n++
+	}
+
+	r.FinishReadBatch()
+	return n, nil
+}
+
 // FinishReadBatch commits the read batch
 func (r *PerfRing) FinishReadBatch() {
 	// Update tail position using atomic store
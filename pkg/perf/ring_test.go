@@ -1,10 +1,14 @@
 package perf
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
 	"unsafe"
 )
 
+var errStopAfterFirst = errors.New("stop after first record")
+
 func TestInitContiguous(t *testing.T) {
 	pageSize := uint64(4096)
 	nPages := uint32(2)
@@ -251,3 +255,459 @@ func TestWraparound(t *testing.T) {
 		t.Errorf("expected 0 bytes remaining, got %d", remaining)
 	}
 }
+
+func TestPeekBytesContiguous(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize ring: %v", err)
+	}
+
+	testData := []byte{10, 20, 30, 40, 50}
+
+	ring.StartWriteBatch()
+	_, err = ring.Write(testData, 1)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	ring.StartReadBatch()
+
+	a, b, err := ring.PeekBytes(0, uint16(len(testData)))
+	if err != nil {
+		t.Fatalf("PeekBytes failed: %v", err)
+	}
+	if b != nil {
+		t.Errorf("expected a nil second slice for a contiguous region, got len %d", len(b))
+	}
+	if string(a) != string(testData) {
+		t.Errorf("expected %v, got %v", testData, a)
+	}
+
+	// The returned slice must point directly into the ring's mmap-backed
+	// data buffer, not a copy.
+	if &a[0] != &ring.data[ring.head&ring.bufMask+uint64(unsafe.Sizeof(PerfEventHeader{}))] {
+		t.Error("expected PeekBytes to return a view into the ring's data buffer")
+	}
+
+	// A sub-range offset into the payload should work too.
+	a, b, err = ring.PeekBytes(2, 2)
+	if err != nil {
+		t.Fatalf("PeekBytes with offset failed: %v", err)
+	}
+	if b != nil {
+		t.Errorf("expected a nil second slice, got len %d", len(b))
+	}
+	if string(a) != string(testData[2:4]) {
+		t.Errorf("expected %v, got %v", testData[2:4], a)
+	}
+
+	// Requesting more than the event's (alignment-padded) size should fail.
+	size, err := ring.PeekSize()
+	if err != nil {
+		t.Fatalf("PeekSize failed: %v", err)
+	}
+	if _, _, err := ring.PeekBytes(0, uint16(size+1)); !errors.Is(err, ErrSizeExceeded) {
+		t.Errorf("expected ErrSizeExceeded, got %v", err)
+	}
+
+	if err := ring.Pop(); err != nil {
+		t.Fatalf("failed to pop: %v", err)
+	}
+	ring.FinishReadBatch()
+}
+
+func TestPeekBytesWraparound(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize ring: %v", err)
+	}
+
+	dataSize := int(pageSize) - int(unsafe.Sizeof(PerfEventHeader{})) - 10
+	testData := make([]byte, dataSize)
+	for i := range testData {
+		testData[i] = byte(i)
+	}
+
+	ring.StartWriteBatch()
+	if _, err = ring.Write(testData, 1); err != nil {
+		t.Fatalf("failed to write first chunk: %v", err)
+	}
+	if _, err = ring.Write(testData, 2); err != nil {
+		t.Fatalf("failed to write second chunk: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	ring.StartReadBatch()
+	if err := ring.Pop(); err != nil {
+		t.Fatalf("failed to pop first chunk: %v", err)
+	}
+	ring.FinishReadBatch()
+
+	// There's now space for one more event, which will wrap around the
+	// buffer end once written.
+	ring.StartWriteBatch()
+	if _, err = ring.Write(testData, 3); err != nil {
+		t.Fatalf("failed to write third chunk: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	ring.StartReadBatch()
+	if err := ring.Pop(); err != nil {
+		t.Fatalf("failed to pop second chunk: %v", err)
+	}
+
+	// The third chunk now wraps around the end of the ring.
+	a, b, err := ring.PeekBytes(0, uint16(dataSize))
+	if err != nil {
+		t.Fatalf("PeekBytes failed: %v", err)
+	}
+	if b == nil {
+		t.Fatal("expected a non-nil second slice for a wrapped region")
+	}
+	got := append(append([]byte(nil), a...), b...)
+	for i := range testData {
+		if got[i] != testData[i] {
+			t.Errorf("mismatch at index %d: expected %d, got %d", i, testData[i], got[i])
+		}
+	}
+
+	// Both views must point into the ring's own data buffer.
+	if &a[0] != &ring.data[0] && &b[0] != &ring.data[0] {
+		t.Error("expected one of the two views to start at the ring buffer's wrap boundary")
+	}
+
+	if err := ring.Pop(); err != nil {
+		t.Fatalf("failed to pop third chunk: %v", err)
+	}
+	ring.FinishReadBatch()
+}
+
+func TestReadBatchContiguous(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize ring: %v", err)
+	}
+
+	records := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+
+	ring.StartWriteBatch()
+	for i, rec := range records {
+		if _, err := ring.Write(rec, uint32(i+1)); err != nil {
+			t.Fatalf("failed to write record %d: %v", i, err)
+		}
+	}
+	ring.FinishWriteBatch()
+
+	var types []uint32
+	var payloads [][]byte
+	n, err := ring.ReadBatch(func(typ uint32, payload []byte) error {
+		types = append(types, typ)
+		payloads = append(payloads, append([]byte(nil), payload...))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadBatch returned error: %v", err)
+	}
+	if n != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), n)
+	}
+	for i, rec := range records {
+		if types[i] != uint32(i+1) {
+			t.Errorf("record %d: expected type %d, got %d", i, i+1, types[i])
+		}
+		// payload is padded to the ring's 8-byte record alignment, so only its
+		// prefix is guaranteed to match what was written.
+		if len(payloads[i]) < len(rec) || string(payloads[i][:len(rec)]) != string(rec) {
+			t.Errorf("record %d: expected payload to start with %q, got %q", i, rec, payloads[i])
+		}
+	}
+
+	if remaining := ring.BytesRemaining(); remaining != 0 {
+		t.Errorf("expected 0 bytes remaining, got %d", remaining)
+	}
+}
+
+func TestReadBatchStitchesWraparound(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize ring: %v", err)
+	}
+
+	// Same setup as TestWraparound: fill most of the ring, pop one record, then
+	// write a third that wraps around the end of the buffer.
+	dataSize := int(pageSize) - int(unsafe.Sizeof(PerfEventHeader{})) - 10
+	testData := make([]byte, dataSize)
+	for i := range testData {
+		testData[i] = byte(i)
+	}
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(testData, 1); err != nil {
+		t.Fatalf("failed to write first chunk: %v", err)
+	}
+	if _, err := ring.Write(testData, 2); err != nil {
+		t.Fatalf("failed to write second chunk: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	// Consume only the first chunk (type 1), then stop before the second so it
+	// stays in the ring exactly as in TestWraparound's "after popping the first
+	// chunk" state.
+	n, err := ring.ReadBatch(func(typ uint32, payload []byte) error {
+		if typ == 1 {
+			return nil
+		}
+		return errStopAfterFirst
+	})
+	if err != errStopAfterFirst {
+		t.Fatalf("expected sentinel error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 record consumed before stopping, got %d", n)
+	}
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(testData, 3); err != nil {
+		t.Fatalf("failed to write third (wrapping) chunk: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	var types []uint32
+	n, err = ring.ReadBatch(func(typ uint32, payload []byte) error {
+		types = append(types, typ)
+		// payload is padded to the ring's 8-byte record alignment; only compare
+		// the prefix that was actually written.
+		for i := range testData {
+			if payload[i] != testData[i] {
+				t.Fatalf("record type %d: mismatch at byte %d: expected %d, got %d", typ, i, testData[i], payload[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadBatch returned error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 records, got %d", n)
+	}
+	if types[0] != 2 || types[1] != 3 {
+		t.Errorf("expected types [2 3], got %v", types)
+	}
+
+	if remaining := ring.BytesRemaining(); remaining != 0 {
+		t.Errorf("expected 0 bytes remaining, got %d", remaining)
+	}
+}
+
+// TestPerfRingDetectsOverrun simulates a producer that wrote more data than
+// the ring can hold while this consumer wasn't reading, by advancing
+// Data_head (the ring's tail, from the consumer's point of view) past
+// head+capacity without the consumer ever having read the intervening data.
+func TestPerfRingDetectsOverrun(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(1)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+
+	capacity := ring.bufMask + 1
+	atomic.StoreUint64(&ring.meta.Data_head, capacity+64)
+	ring.StartReadBatch()
+
+	if _, err := ring.PeekSize(); !errors.Is(err, ErrLostData) {
+		t.Fatalf("expected ErrLostData from PeekSize, got %v", err)
+	}
+	if err := ring.Pop(); !errors.Is(err, ErrLostData) {
+		t.Fatalf("expected ErrLostData from Pop, got %v", err)
+	}
+	if _, err := ring.ReadBatch(func(uint32, []byte) error { return nil }); !errors.Is(err, ErrLostData) {
+		t.Fatalf("expected ErrLostData from ReadBatch, got %v", err)
+	}
+
+	if got := ring.LostBytes(); got != 64 {
+		t.Errorf("expected 64 lost bytes, got %d", got)
+	}
+
+	if skipped := ring.Resync(); skipped != 64 {
+		t.Errorf("expected Resync to report 64 skipped bytes, got %d", skipped)
+	}
+	if got := ring.LostBytes(); got != 0 {
+		t.Errorf("expected LostBytes to be 0 after Resync, got %d", got)
+	}
+}
+
+// TestReaderPropagatesLostData verifies that Reader surfaces ErrLostData
+// from a ring that was overrun, and that it recovers once the caller
+// resyncs the ring.
+func TestReaderPropagatesLostData(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(1)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	capacity := ring.bufMask + 1
+	atomic.StoreUint64(&ring.meta.Data_head, capacity+8)
+
+	if err := reader.Start(); !errors.Is(err, ErrLostData) {
+		t.Fatalf("expected Start to surface ErrLostData, got %v", err)
+	}
+
+	if skipped := ring.Resync(); skipped != 8 {
+		t.Errorf("expected Resync to skip 8 bytes, got %d", skipped)
+	}
+
+	if err := reader.Start(); err != nil {
+		t.Fatalf("expected Start to succeed after Resync, got %v", err)
+	}
+	reader.Finish()
+}
+
+// TestWriteOverwriteDropsOldestEvents verifies that WriteOverwrite never
+// fails with ErrNoSpace, instead advancing head past whole events to make
+// room, and that it accounts for every dropped event/byte.
+func TestWriteOverwriteDropsOldestEvents(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(1)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguousOverwrite(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize overwrite ring: %v", err)
+	}
+
+	eventType := uint32(1)
+	payload := make([]byte, 56) // 64-byte event once the header is added
+
+	capacity := ring.bufMask + 1
+	eventsPerRing := int(capacity / 64)
+
+	ring.StartWriteBatch()
+	for i := 0; i < eventsPerRing; i++ {
+		if _, err := ring.WriteOverwrite(payload, eventType); err != nil {
+			t.Fatalf("unexpected error filling ring: %v", err)
+		}
+	}
+	ring.FinishWriteBatch()
+
+	if got := ring.DroppedBytes(); got != 0 {
+		t.Fatalf("expected 0 dropped bytes while filling, got %d", got)
+	}
+
+	// The ring is now full; the next two writes must each evict the oldest
+	// event instead of failing.
+	ring.StartWriteBatch()
+	if _, err := ring.WriteOverwrite(payload, eventType); err != nil {
+		t.Fatalf("WriteOverwrite returned error instead of dropping old data: %v", err)
+	}
+	if _, err := ring.WriteOverwrite(payload, eventType); err != nil {
+		t.Fatalf("WriteOverwrite returned error instead of dropping old data: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	if got := ring.DroppedEvents(); got != 2 {
+		t.Errorf("expected 2 dropped events, got %d", got)
+	}
+	if got := ring.DroppedBytes(); got != 128 {
+		t.Errorf("expected 128 dropped bytes, got %d", got)
+	}
+
+	// The ring should still only report eventsPerRing events available, the
+	// oldest two having been evicted.
+	ring.StartReadBatch()
+	count := 0
+	for {
+		if err := ring.Pop(); err != nil {
+			if errors.Is(err, ErrBufferEmpty) {
+				break
+			}
+			t.Fatalf("unexpected error popping event %d: %v", count, err)
+		}
+		count++
+	}
+	if count != eventsPerRing {
+		t.Errorf("expected %d surviving events, got %d", eventsPerRing, count)
+	}
+	ring.FinishReadBatch()
+}
+
+// TestStartReadBatchReportsOverwriteSkip verifies that a reader that hasn't
+// consumed anything since its last batch learns, via StartReadBatch's return
+// value, how many bytes WriteOverwrite dropped out from under it. It uses
+// separate PerfRing instances over the same backing buffer for the writer
+// and reader sides, mirroring how a real producer and consumer (e.g. the
+// kernel and a userspace reader) each keep their own local head/tail view of
+// shared memory.
+func TestStartReadBatchReportsOverwriteSkip(t *testing.T) {
+	pageSize := uint64(4096)
+	nPages := uint32(1)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	writer, err := InitContiguousOverwrite(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize writer ring: %v", err)
+	}
+	reader, err := InitContiguousOverwrite(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize reader ring: %v", err)
+	}
+
+	eventType := uint32(1)
+	payload := make([]byte, 56)
+	capacity := writer.bufMask + 1
+	eventsPerRing := int(capacity / 64)
+
+	writer.StartWriteBatch()
+	for i := 0; i < eventsPerRing; i++ {
+		if _, err := writer.WriteOverwrite(payload, eventType); err != nil {
+			t.Fatalf("unexpected error filling ring: %v", err)
+		}
+	}
+	writer.FinishWriteBatch()
+
+	// The reader observes the full ring once, establishing its head, but
+	// doesn't consume anything.
+	if skipped := reader.StartReadBatch(); skipped != 0 {
+		t.Fatalf("expected 0 skipped bytes on first batch, got %d", skipped)
+	}
+
+	// Now the producer drops the oldest event to make room for a new one,
+	// without any Pop happening in between.
+	writer.StartWriteBatch()
+	if _, err := writer.WriteOverwrite(payload, eventType); err != nil {
+		t.Fatalf("unexpected error dropping old data: %v", err)
+	}
+	writer.FinishWriteBatch()
+
+	if skipped := reader.StartReadBatch(); skipped != 64 {
+		t.Errorf("expected StartReadBatch to report 64 skipped bytes, got %d", skipped)
+	}
+}
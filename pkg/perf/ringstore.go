@@ -0,0 +1,336 @@
+package perf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RingDesc identifies one ring buffer within a RingStore: which Kind of
+// backing it uses, which CPU it monitors, and which Generation of that
+// CPU's ring this is. Generation is bumped on each rotation, so a new ring
+// can be Created and the old one Removed without reusing a name a reader
+// might still be using.
+type RingDesc struct {
+	Kind       string
+	CPU        int
+	Generation uint64
+}
+
+// RingDesc.Kind values.
+const (
+	RingKindPerf = "perf"
+	RingKindMem  = "mem"
+	RingKindFile = "file"
+)
+
+// RingStore manages a set of ring buffers addressed by RingDesc, so a
+// collector can enumerate, create, and retire rings - e.g. rotating a CPU's
+// ring to a new generation - without owning a fixed slice of storage objects
+// itself. Three implementations are provided: MemoryRingStore (wrapping
+// MemoryRingStorage), PerfRingStore (wrapping MmapRingStorage), and
+// FileRingStore (a directory of FileRingStorage files).
+//
+// This is a standalone library abstraction: no reader loop in this tree has
+// been migrated from owning its storage directly to going through a
+// RingStore yet (pkg/perf_ebpf's reader, for one, still depends on
+// MmapRingStorage's concrete type directly).
+type RingStore interface {
+	// List returns every ring currently known to the store.
+	List() ([]RingDesc, error)
+	// Open returns the handle for an existing ring.
+	Open(desc RingDesc) (RingHandle, error)
+	// Create allocates a new ring for desc, which must not already exist.
+	Create(desc RingDesc) (RingHandle, error)
+	// Remove releases and forgets the ring at desc. It is a no-op if desc
+	// doesn't exist.
+	Remove(desc RingDesc) error
+	// Snapshot copies handle's current contents into a newly created ring
+	// at dst within this store - e.g. to persist a live in-memory or perf
+	// ring to a file-backed one before rotating the live ring away.
+	Snapshot(handle RingHandle, dst RingDesc) error
+}
+
+func sortRingDescs(descs []RingDesc) {
+	sort.Slice(descs, func(i, j int) bool {
+		if descs[i].Kind != descs[j].Kind {
+			return descs[i].Kind < descs[j].Kind
+		}
+		if descs[i].CPU != descs[j].CPU {
+			return descs[i].CPU < descs[j].CPU
+		}
+		return descs[i].Generation < descs[j].Generation
+	})
+}
+
+// copyRingData copies src's data pages into dst, which must be at least as
+// large. It backs every store's Snapshot implementation.
+func copyRingData(src, dst RingHandle) error {
+	srcData, dstData := src.Data(), dst.Data()
+	if len(dstData) < len(srcData) {
+		return fmt.Errorf("snapshot destination is smaller than source: %d < %d bytes", len(dstData), len(srcData))
+	}
+	copy(dstData, srcData)
+	return nil
+}
+
+// MemoryRingStore is a RingStore backed by plain memory allocations
+// (MemoryRingStorage), useful for tests and inter-thread communication where
+// nothing needs to survive a process restart.
+type MemoryRingStore struct {
+	nPages uint32
+
+	mu      sync.Mutex
+	handles map[RingDesc]*MemoryRingStorage
+}
+
+// NewMemoryRingStore creates a store whose rings each hold nPages data pages.
+func NewMemoryRingStore(nPages uint32) *MemoryRingStore {
+	return &MemoryRingStore{nPages: nPages, handles: make(map[RingDesc]*MemoryRingStorage)}
+}
+
+func (s *MemoryRingStore) List() ([]RingDesc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	descs := make([]RingDesc, 0, len(s.handles))
+	for desc := range s.handles {
+		descs = append(descs, desc)
+	}
+	sortRingDescs(descs)
+	return descs, nil
+}
+
+func (s *MemoryRingStore) Open(desc RingDesc) (RingHandle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.handles[desc]
+	if !ok {
+		return nil, fmt.Errorf("memory ring store: no ring for %+v", desc)
+	}
+	return h, nil
+}
+
+func (s *MemoryRingStore) Create(desc RingDesc) (RingHandle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.handles[desc]; exists {
+		return nil, fmt.Errorf("memory ring store: ring %+v already exists", desc)
+	}
+	h, err := NewMemoryRingStorage(s.nPages)
+	if err != nil {
+		return nil, err
+	}
+	s.handles[desc] = h
+	return h, nil
+}
+
+func (s *MemoryRingStore) Remove(desc RingDesc) error {
+	s.mu.Lock()
+	h, ok := s.handles[desc]
+	delete(s.handles, desc)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return h.Close()
+}
+
+func (s *MemoryRingStore) Snapshot(handle RingHandle, dst RingDesc) error {
+	target, err := s.Create(dst)
+	if err != nil {
+		return err
+	}
+	return copyRingData(handle, target)
+}
+
+// PerfRingStore is a RingStore backed by real perf_event_open ring buffers,
+// one per CPU (MmapRingStorage). Rings aren't nameable the way files are, so
+// Open only succeeds for a desc this same store instance Created.
+type PerfRingStore struct {
+	nPages         uint32
+	watermarkBytes uint32
+
+	mu      sync.Mutex
+	handles map[RingDesc]*MmapRingStorage
+}
+
+// NewPerfRingStore creates a store whose rings each hold nPages data pages,
+// waking up readers every watermarkBytes (0 wakes up on every event).
+func NewPerfRingStore(nPages uint32, watermarkBytes uint32) *PerfRingStore {
+	return &PerfRingStore{nPages: nPages, watermarkBytes: watermarkBytes, handles: make(map[RingDesc]*MmapRingStorage)}
+}
+
+func (s *PerfRingStore) List() ([]RingDesc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	descs := make([]RingDesc, 0, len(s.handles))
+	for desc := range s.handles {
+		descs = append(descs, desc)
+	}
+	sortRingDescs(descs)
+	return descs, nil
+}
+
+func (s *PerfRingStore) Open(desc RingDesc) (RingHandle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.handles[desc]
+	if !ok {
+		return nil, fmt.Errorf("perf ring store: no ring for %+v", desc)
+	}
+	return h, nil
+}
+
+func (s *PerfRingStore) Create(desc RingDesc) (RingHandle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.handles[desc]; exists {
+		return nil, fmt.Errorf("perf ring store: ring %+v already exists", desc)
+	}
+	h, err := NewMmapRingStorage(desc.CPU, s.nPages, s.watermarkBytes)
+	if err != nil {
+		return nil, err
+	}
+	s.handles[desc] = h
+	return h, nil
+}
+
+func (s *PerfRingStore) Remove(desc RingDesc) error {
+	s.mu.Lock()
+	h, ok := s.handles[desc]
+	delete(s.handles, desc)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return h.Close()
+}
+
+func (s *PerfRingStore) Snapshot(handle RingHandle, dst RingDesc) error {
+	target, err := s.Create(dst)
+	if err != nil {
+		return err
+	}
+	return copyRingData(handle, target)
+}
+
+// FileRingStore is a RingStore backed by FileRingStorage files under Dir,
+// one per ring, named so List can rediscover every ring that survived a
+// process restart without any separate bookkeeping.
+type FileRingStore struct {
+	dir     string
+	nPages  uint32
+	version uint8
+}
+
+// NewFileRingStore creates a store rooted at dir; rings it Creates hold
+// nPages data pages and use the given FileRingStorage version.
+func NewFileRingStore(dir string, nPages uint32, version uint8) *FileRingStore {
+	return &FileRingStore{dir: dir, nPages: nPages, version: version}
+}
+
+const fileRingStoreSuffix = ".ring"
+
+func fileRingStoreName(desc RingDesc) string {
+	return fmt.Sprintf("%s-cpu%d-gen%016x%s", desc.Kind, desc.CPU, desc.Generation, fileRingStoreSuffix)
+}
+
+// parseFileRingStoreName is the inverse of fileRingStoreName, used by List
+// to recover every ring's RingDesc from its filename alone.
+func parseFileRingStoreName(name string) (RingDesc, bool) {
+	base := strings.TrimSuffix(name, fileRingStoreSuffix)
+	if base == name {
+		return RingDesc{}, false
+	}
+
+	kindEnd := strings.LastIndex(base, "-cpu")
+	if kindEnd < 0 {
+		return RingDesc{}, false
+	}
+	rest := base[kindEnd+len("-cpu"):]
+
+	genSep := strings.LastIndex(rest, "-gen")
+	if genSep < 0 {
+		return RingDesc{}, false
+	}
+
+	cpu, err := strconv.Atoi(rest[:genSep])
+	if err != nil {
+		return RingDesc{}, false
+	}
+	gen, err := strconv.ParseUint(rest[genSep+len("-gen"):], 16, 64)
+	if err != nil {
+		return RingDesc{}, false
+	}
+	return RingDesc{Kind: base[:kindEnd], CPU: cpu, Generation: gen}, true
+}
+
+func (s *FileRingStore) path(desc RingDesc) string {
+	return filepath.Join(s.dir, fileRingStoreName(desc))
+}
+
+func (s *FileRingStore) List() ([]RingDesc, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing ring store directory: %w", err)
+	}
+
+	var descs []RingDesc
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if desc, ok := parseFileRingStoreName(e.Name()); ok {
+			descs = append(descs, desc)
+		}
+	}
+	sortRingDescs(descs)
+	return descs, nil
+}
+
+func (s *FileRingStore) Open(desc RingDesc) (RingHandle, error) {
+	return OpenFileRingStorage(s.path(desc))
+}
+
+func (s *FileRingStore) Create(desc RingDesc) (RingHandle, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating ring store directory: %w", err)
+	}
+	return NewFileRingStorage(s.path(desc), s.nPages, s.version)
+}
+
+func (s *FileRingStore) Remove(desc RingDesc) error {
+	if err := os.Remove(s.path(desc)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing ring %+v: %w", desc, err)
+	}
+	return nil
+}
+
+// Snapshot persists handle's current contents to a new file at dst and
+// closes it, unlike MemoryRingStore/PerfRingStore's Snapshot: a file-backed
+// copy is meant as a durable point-in-time backup, not another live ring, so
+// there's no reason to hold its mmap open afterward.
+func (s *FileRingStore) Snapshot(handle RingHandle, dst RingDesc) error {
+	target, err := s.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := copyRingData(handle, target); err != nil {
+		target.Close()
+		return err
+	}
+	if fileTarget, ok := target.(*FileRingStorage); ok {
+		if err := fileTarget.Sync(); err != nil {
+			target.Close()
+			return err
+		}
+	}
+	return target.Close()
+}
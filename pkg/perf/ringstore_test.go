@@ -0,0 +1,174 @@
+package perf
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMemoryRingStoreLifecycle(t *testing.T) {
+	store := NewMemoryRingStore(2)
+
+	desc := RingDesc{Kind: RingKindMem, CPU: 0, Generation: 1}
+	handle, err := store.Create(desc)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := store.Create(desc); err == nil {
+		t.Error("expected Create to fail for a desc that already exists")
+	}
+
+	descs, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(descs) != 1 || descs[0] != desc {
+		t.Errorf("expected List to report [%+v], got %+v", desc, descs)
+	}
+
+	opened, err := store.Open(desc)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if opened != handle {
+		t.Error("expected Open to return the same handle Create returned")
+	}
+
+	if err := store.Remove(desc); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := store.Open(desc); err == nil {
+		t.Error("expected Open to fail after Remove")
+	}
+	// Removing again should be a no-op, not an error.
+	if err := store.Remove(desc); err != nil {
+		t.Errorf("expected Remove on a missing desc to be a no-op, got %v", err)
+	}
+}
+
+func TestMemoryRingStoreSnapshot(t *testing.T) {
+	store := NewMemoryRingStore(1)
+
+	src := RingDesc{Kind: RingKindMem, CPU: 0, Generation: 1}
+	handle, err := store.Create(src)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	copy(handle.Data(), []byte("hello ring"))
+
+	dst := RingDesc{Kind: RingKindFile, CPU: 0, Generation: 2}
+	if err := store.Snapshot(handle, dst); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	snapshot, err := store.Open(dst)
+	if err != nil {
+		t.Fatalf("Open snapshot: %v", err)
+	}
+	if string(snapshot.Data()[:10]) != "hello ring" {
+		t.Errorf("expected snapshot to contain source data, got %q", snapshot.Data()[:10])
+	}
+}
+
+func TestFileRingStoreLifecycle(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	dir := t.TempDir()
+	store := NewFileRingStore(dir, 1, FileRingStorageV1)
+
+	descA := RingDesc{Kind: RingKindFile, CPU: 0, Generation: 1}
+	descB := RingDesc{Kind: RingKindFile, CPU: 1, Generation: 1}
+
+	if _, err := store.Create(descA); err != nil {
+		t.Fatalf("Create descA: %v", err)
+	}
+	if _, err := store.Create(descB); err != nil {
+		t.Fatalf("Create descB: %v", err)
+	}
+
+	descs, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(descs) != 2 || descs[0] != descA || descs[1] != descB {
+		t.Errorf("expected List to report [%+v %+v], got %+v", descA, descB, descs)
+	}
+
+	if _, err := store.Open(descA); err != nil {
+		t.Fatalf("Open descA: %v", err)
+	}
+
+	if err := store.Remove(descA); err != nil {
+		t.Fatalf("Remove descA: %v", err)
+	}
+	if _, err := store.Open(descA); err == nil {
+		t.Error("expected Open to fail after Remove")
+	}
+
+	remaining, err := store.List()
+	if err != nil {
+		t.Fatalf("List after Remove: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0] != descB {
+		t.Errorf("expected only descB to remain, got %+v", remaining)
+	}
+}
+
+func TestFileRingStoreSnapshotPersistsAndCloses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping test on non-linux platform")
+	}
+
+	dir := t.TempDir()
+	mem := NewMemoryRingStore(1)
+	src := RingDesc{Kind: RingKindMem, CPU: 0, Generation: 1}
+	handle, err := mem.Create(src)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	copy(handle.Data(), []byte("snapshot me"))
+
+	files := NewFileRingStore(dir, 1, FileRingStorageV1)
+	dst := RingDesc{Kind: RingKindFile, CPU: 0, Generation: 2}
+	if err := files.Snapshot(handle, dst); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	if _, err := filepath.Glob(filepath.Join(dir, "*")); err != nil {
+		t.Fatalf("globbing directory: %v", err)
+	}
+
+	reopened, err := files.Open(dst)
+	if err != nil {
+		t.Fatalf("Open snapshot: %v", err)
+	}
+	defer reopened.Close()
+	if string(reopened.Data()[:11]) != "snapshot me" {
+		t.Errorf("expected reopened snapshot to contain source data, got %q", reopened.Data()[:11])
+	}
+}
+
+func TestFileRingDescRoundTrip(t *testing.T) {
+	descs := []RingDesc{
+		{Kind: RingKindFile, CPU: 0, Generation: 0},
+		{Kind: RingKindPerf, CPU: 7, Generation: 42},
+		{Kind: RingKindMem, CPU: 3, Generation: 0xdeadbeef},
+	}
+	for _, desc := range descs {
+		name := fileRingStoreName(desc)
+		got, ok := parseFileRingStoreName(name)
+		if !ok {
+			t.Fatalf("parseFileRingStoreName(%q) failed to parse", name)
+		}
+		if got != desc {
+			t.Errorf("round-trip mismatch: want %+v, got %+v (name %q)", desc, got, name)
+		}
+	}
+
+	if _, ok := parseFileRingStoreName("not-a-ring-file.txt"); ok {
+		t.Error("expected parseFileRingStoreName to reject an unrelated filename")
+	}
+}
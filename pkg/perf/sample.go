@@ -0,0 +1,277 @@
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Additional perf_event_header types the collector decodes, beyond
+// PERF_RECORD_SAMPLE and PERF_RECORD_LOST (see reader.go).
+const (
+	// PERF_RECORD_THROTTLE is emitted when the kernel starts throttling a
+	// sampled event because its rate exceeded perf_event_max_sample_rate.
+	PERF_RECORD_THROTTLE = 5
+	// PERF_RECORD_UNTHROTTLE is emitted when throttling for an event stops.
+	PERF_RECORD_UNTHROTTLE = 6
+)
+
+// PERF_SAMPLE_* are the sample_type bits DecodeSample understands, matching
+// the kernel's perf_event.h values and field order. Bits not listed here
+// (e.g. PERF_SAMPLE_STREAM_ID, PERF_SAMPLE_IDENTIFIER) aren't decoded.
+const (
+	PERF_SAMPLE_IP        uint64 = 1 << 0
+	PERF_SAMPLE_TID       uint64 = 1 << 1
+	PERF_SAMPLE_TIME      uint64 = 1 << 2
+	PERF_SAMPLE_ADDR      uint64 = 1 << 3
+	PERF_SAMPLE_READ      uint64 = 1 << 4
+	PERF_SAMPLE_CALLCHAIN uint64 = 1 << 5
+	PERF_SAMPLE_ID        uint64 = 1 << 6
+	PERF_SAMPLE_CPU       uint64 = 1 << 7
+	PERF_SAMPLE_PERIOD    uint64 = 1 << 8
+	PERF_SAMPLE_RAW       uint64 = 1 << 10
+)
+
+// ErrUnsupportedSampleType is returned by DecodeSample when mask sets a bit
+// it doesn't know how to decode.
+var ErrUnsupportedSampleType = errors.New("perf: unsupported PERF_SAMPLE_* bit in mask")
+
+// knownSampleTypeBits is the union of every PERF_SAMPLE_* bit DecodeSample
+// understands.
+const knownSampleTypeBits = PERF_SAMPLE_IP | PERF_SAMPLE_TID | PERF_SAMPLE_TIME |
+	PERF_SAMPLE_ADDR | PERF_SAMPLE_READ | PERF_SAMPLE_CALLCHAIN | PERF_SAMPLE_ID |
+	PERF_SAMPLE_CPU | PERF_SAMPLE_PERIOD | PERF_SAMPLE_RAW
+
+// Sample holds the fields of a decoded PERF_RECORD_SAMPLE record. Only the
+// fields whose bit was set in the mask passed to DecodeSample are populated;
+// the rest are left zero. Read, Callchain and Raw are copied out of the
+// ring (they can't be represented as fixed-offset views), so callers that
+// only need the fixed-size fields (IP, Tid, Time, ...) can pass a mask
+// without PERF_SAMPLE_READ/CALLCHAIN/RAW to avoid those allocations.
+type Sample struct {
+	IP        uint64
+	Pid, Tid  uint32
+	Time      uint64
+	Addr      uint64
+	ID        uint64
+	CPU, Res  uint32
+	Period    uint64
+	Read      []byte // raw struct read_format bytes; layout depends on the event's read_format
+	Callchain []uint64
+	Raw       []byte
+}
+
+// LostRecord holds the fields of a decoded PERF_RECORD_LOST record.
+type LostRecord struct {
+	ID   uint64
+	Lost uint64
+}
+
+// ThrottleRecord holds the fields of a decoded PERF_RECORD_THROTTLE or
+// PERF_RECORD_UNTHROTTLE record.
+type ThrottleRecord struct {
+	Time     uint64
+	ID       uint64
+	StreamID uint64
+}
+
+// DecodeSample decodes the ring's current PERF_RECORD_SAMPLE event according
+// to mask, a PERF_SAMPLE_* bitmask matching the sample_type the event was
+// configured with, without consuming it. Fixed-size fields are read directly
+// from the mmapped ring via PeekBytes; the variable-length Read, Callchain
+// and Raw fields are copied out since they can wrap the ring's buffer
+// boundary. DecodeSample returns ErrUnsupportedSampleType if mask sets a bit
+// it doesn't decode, and whatever PeekBytes/PeekSize return on a malformed
+// or truncated record.
+func (r *PerfRing) DecodeSample(mask uint64) (Sample, error) {
+	var s Sample
+
+	if mask&^knownSampleTypeBits != 0 {
+		return s, ErrUnsupportedSampleType
+	}
+
+	var offset uint16
+
+	readU32 := func() (uint32, error) {
+		a, b, err := r.PeekBytes(offset, 4)
+		if err != nil {
+			return 0, err
+		}
+		offset += 4
+		if b == nil {
+			return binary.LittleEndian.Uint32(a), nil
+		}
+		var buf [4]byte
+		n := copy(buf[:], a)
+		copy(buf[n:], b)
+		return binary.LittleEndian.Uint32(buf[:]), nil
+	}
+
+	readU64 := func() (uint64, error) {
+		a, b, err := r.PeekBytes(offset, 8)
+		if err != nil {
+			return 0, err
+		}
+		offset += 8
+		if b == nil {
+			return binary.LittleEndian.Uint64(a), nil
+		}
+		var buf [8]byte
+		n := copy(buf[:], a)
+		copy(buf[n:], b)
+		return binary.LittleEndian.Uint64(buf[:]), nil
+	}
+
+	// readBytes copies out a variable-length region, since it may need to be
+	// retained past the next Pop/ReadBatch/FinishReadBatch.
+	readBytes := func(n uint16) ([]byte, error) {
+		a, b, err := r.PeekBytes(offset, n)
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+		out := make([]byte, n)
+		k := copy(out, a)
+		copy(out[k:], b)
+		return out, nil
+	}
+
+	var err error
+	if mask&PERF_SAMPLE_IP != 0 {
+		if s.IP, err = readU64(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_TID != 0 {
+		if s.Pid, err = readU32(); err != nil {
+			return s, err
+		}
+		if s.Tid, err = readU32(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_TIME != 0 {
+		if s.Time, err = readU64(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_ADDR != 0 {
+		if s.Addr, err = readU64(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_ID != 0 {
+		if s.ID, err = readU64(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_CPU != 0 {
+		if s.CPU, err = readU32(); err != nil {
+			return s, err
+		}
+		if s.Res, err = readU32(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_PERIOD != 0 {
+		if s.Period, err = readU64(); err != nil {
+			return s, err
+		}
+	}
+	if mask&PERF_SAMPLE_READ != 0 {
+		// The read_format layout depends on the event's read_format flags
+		// (PERF_FORMAT_GROUP, _ID, ...), which DecodeSample doesn't know, so
+		// it hands back the rest of the record; callers that enabled
+		// PERF_SAMPLE_READ must not also request CALLCHAIN/RAW.
+		size, sizeErr := r.PeekSize()
+		if sizeErr != nil {
+			return s, sizeErr
+		}
+		if s.Read, err = readBytes(uint16(size) - offset); err != nil {
+			return s, err
+		}
+		return s, nil
+	}
+	if mask&PERF_SAMPLE_CALLCHAIN != 0 {
+		nr, nrErr := readU64()
+		if nrErr != nil {
+			return s, nrErr
+		}
+		s.Callchain = make([]uint64, nr)
+		for i := range s.Callchain {
+			if s.Callchain[i], err = readU64(); err != nil {
+				return s, err
+			}
+		}
+	}
+	if mask&PERF_SAMPLE_RAW != 0 {
+		rawSize, rawErr := readU32()
+		if rawErr != nil {
+			return s, rawErr
+		}
+		if s.Raw, err = readBytes(uint16(rawSize)); err != nil {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// DecodeLost decodes the ring's current PERF_RECORD_LOST event, without
+// consuming it.
+func (r *PerfRing) DecodeLost() (LostRecord, error) {
+	var lr LostRecord
+
+	id, err := r.peekU64At(0)
+	if err != nil {
+		return lr, err
+	}
+	lost, err := r.peekU64At(8)
+	if err != nil {
+		return lr, err
+	}
+
+	lr.ID = id
+	lr.Lost = lost
+	return lr, nil
+}
+
+// DecodeThrottle decodes the ring's current PERF_RECORD_THROTTLE or
+// PERF_RECORD_UNTHROTTLE event, without consuming it.
+func (r *PerfRing) DecodeThrottle() (ThrottleRecord, error) {
+	var tr ThrottleRecord
+
+	time, err := r.peekU64At(0)
+	if err != nil {
+		return tr, err
+	}
+	id, err := r.peekU64At(8)
+	if err != nil {
+		return tr, err
+	}
+	streamID, err := r.peekU64At(16)
+	if err != nil {
+		return tr, err
+	}
+
+	tr.Time = time
+	tr.ID = id
+	tr.StreamID = streamID
+	return tr, nil
+}
+
+// peekU64At reads a little-endian u64 at offset bytes into the current
+// record's payload, stitching the two PeekBytes views together if the value
+// straddles the ring's buffer boundary.
+func (r *PerfRing) peekU64At(offset uint16) (uint64, error) {
+	a, b, err := r.PeekBytes(offset, 8)
+	if err != nil {
+		return 0, err
+	}
+	if b == nil {
+		return binary.LittleEndian.Uint64(a), nil
+	}
+	var buf [8]byte
+	n := copy(buf[:], a)
+	copy(buf[n:], b)
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
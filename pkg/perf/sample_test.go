@@ -0,0 +1,198 @@
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// sampleTestEventType is a stand-in for a real PERF_RECORD_SAMPLE type: it
+// lets these tests write exact sample_type-shaped payloads without
+// PerfRing.Write's automatic 4-byte size prefix for PERF_RECORD_SAMPLE
+// (which encodes this codebase's own PERF_SAMPLE_RAW-only wire format, not
+// the general multi-field layouts DecodeSample also needs to support).
+// DecodeSample itself doesn't look at the record type, so this is safe.
+const sampleTestEventType = 100
+
+func newSampleTestRing(t *testing.T) *PerfRing {
+	t.Helper()
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to initialize ring: %v", err)
+	}
+	return ring
+}
+
+func TestDecodeSampleFixedFields(t *testing.T) {
+	ring := newSampleTestRing(t)
+
+	payload := make([]byte, 8+4+4+8+8+8+4+4+8)
+	off := 0
+	putU64 := func(v uint64) {
+		binary.LittleEndian.PutUint64(payload[off:], v)
+		off += 8
+	}
+	putU32 := func(v uint32) {
+		binary.LittleEndian.PutUint32(payload[off:], v)
+		off += 4
+	}
+
+	putU64(0xdeadbeef) // IP
+	putU32(111)        // Pid
+	putU32(222)        // Tid
+	putU64(1000)       // Time
+	putU64(0x1000)     // Addr
+	putU64(42)         // ID
+	putU32(3)          // CPU
+	putU32(0)          // Res
+	putU64(999)        // Period
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(payload, sampleTestEventType); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	ring.FinishWriteBatch()
+	ring.StartReadBatch()
+
+	mask := PERF_SAMPLE_IP | PERF_SAMPLE_TID | PERF_SAMPLE_TIME | PERF_SAMPLE_ADDR |
+		PERF_SAMPLE_ID | PERF_SAMPLE_CPU | PERF_SAMPLE_PERIOD
+
+	s, err := ring.DecodeSample(mask)
+	if err != nil {
+		t.Fatalf("DecodeSample failed: %v", err)
+	}
+
+	if s.IP != 0xdeadbeef {
+		t.Errorf("expected IP 0xdeadbeef, got %#x", s.IP)
+	}
+	if s.Pid != 111 || s.Tid != 222 {
+		t.Errorf("expected pid=111 tid=222, got pid=%d tid=%d", s.Pid, s.Tid)
+	}
+	if s.Time != 1000 {
+		t.Errorf("expected time 1000, got %d", s.Time)
+	}
+	if s.Addr != 0x1000 {
+		t.Errorf("expected addr 0x1000, got %#x", s.Addr)
+	}
+	if s.ID != 42 {
+		t.Errorf("expected id 42, got %d", s.ID)
+	}
+	if s.CPU != 3 {
+		t.Errorf("expected cpu 3, got %d", s.CPU)
+	}
+	if s.Period != 999 {
+		t.Errorf("expected period 999, got %d", s.Period)
+	}
+}
+
+func TestDecodeSampleRawAndCallchain(t *testing.T) {
+	ring := newSampleTestRing(t)
+
+	rawData := []byte{1, 2, 3, 4, 5}
+	callchain := []uint64{0x1111, 0x2222, 0x3333}
+
+	var payload []byte
+	nr := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nr, uint64(len(callchain)))
+	payload = append(payload, nr...)
+	for _, ip := range callchain {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, ip)
+		payload = append(payload, buf...)
+	}
+	rawSize := make([]byte, 4)
+	binary.LittleEndian.PutUint32(rawSize, uint32(len(rawData)))
+	payload = append(payload, rawSize...)
+	payload = append(payload, rawData...)
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(payload, sampleTestEventType); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	ring.FinishWriteBatch()
+	ring.StartReadBatch()
+
+	s, err := ring.DecodeSample(PERF_SAMPLE_CALLCHAIN | PERF_SAMPLE_RAW)
+	if err != nil {
+		t.Fatalf("DecodeSample failed: %v", err)
+	}
+
+	if len(s.Callchain) != len(callchain) {
+		t.Fatalf("expected %d callchain entries, got %d", len(callchain), len(s.Callchain))
+	}
+	for i, ip := range callchain {
+		if s.Callchain[i] != ip {
+			t.Errorf("callchain[%d]: expected %#x, got %#x", i, ip, s.Callchain[i])
+		}
+	}
+	if string(s.Raw) != string(rawData) {
+		t.Errorf("expected raw %v, got %v", rawData, s.Raw)
+	}
+}
+
+func TestDecodeSampleRejectsUnsupportedMask(t *testing.T) {
+	ring := newSampleTestRing(t)
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write([]byte{1, 2, 3, 4}, sampleTestEventType); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	ring.FinishWriteBatch()
+	ring.StartReadBatch()
+
+	const perfSampleStreamID = 1 << 9
+	if _, err := ring.DecodeSample(perfSampleStreamID); !errors.Is(err, ErrUnsupportedSampleType) {
+		t.Errorf("expected ErrUnsupportedSampleType, got %v", err)
+	}
+}
+
+func TestDecodeLost(t *testing.T) {
+	ring := newSampleTestRing(t)
+
+	payload := make([]byte, 16)
+	binary.LittleEndian.PutUint64(payload[0:8], 7)
+	binary.LittleEndian.PutUint64(payload[8:16], 123)
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(payload, PERF_RECORD_LOST); err != nil {
+		t.Fatalf("failed to write lost record: %v", err)
+	}
+	ring.FinishWriteBatch()
+	ring.StartReadBatch()
+
+	lr, err := ring.DecodeLost()
+	if err != nil {
+		t.Fatalf("DecodeLost failed: %v", err)
+	}
+	if lr.ID != 7 || lr.Lost != 123 {
+		t.Errorf("expected {id:7, lost:123}, got %+v", lr)
+	}
+}
+
+func TestDecodeThrottle(t *testing.T) {
+	ring := newSampleTestRing(t)
+
+	payload := make([]byte, 24)
+	binary.LittleEndian.PutUint64(payload[0:8], 1000)
+	binary.LittleEndian.PutUint64(payload[8:16], 5)
+	binary.LittleEndian.PutUint64(payload[16:24], 9)
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(payload, PERF_RECORD_THROTTLE); err != nil {
+		t.Fatalf("failed to write throttle record: %v", err)
+	}
+	ring.FinishWriteBatch()
+	ring.StartReadBatch()
+
+	tr, err := ring.DecodeThrottle()
+	if err != nil {
+		t.Fatalf("DecodeThrottle failed: %v", err)
+	}
+	if tr.Time != 1000 || tr.ID != 5 || tr.StreamID != 9 {
+		t.Errorf("expected {time:1000, id:5, streamID:9}, got %+v", tr)
+	}
+}
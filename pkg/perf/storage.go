@@ -8,8 +8,10 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-// RingStorage defines the interface for perf ring buffer storage
-type RingStorage interface {
+// RingHandle defines the interface for a single ring buffer's storage: the
+// raw mmap'd or allocated bytes, independent of where or how they're kept.
+// See RingStore for managing a set of handles by RingDesc.
+type RingHandle interface {
 	// Data returns the raw data buffer containing metadata page and data pages
 	Data() []byte
 	// NumDataPages returns the number of data pages in the ring buffer
@@ -22,6 +24,10 @@ type RingStorage interface {
 	FileDescriptor() int
 }
 
+// RingStorage is the original name for RingHandle, kept as an alias so
+// existing references to it keep compiling.
+type RingStorage = RingHandle
+
 // MemoryRingStorage implements RingStorage using regular memory allocation
 // This is useful for testing and inter-thread communication
 type MemoryRingStorage struct {
@@ -0,0 +1,393 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unvariance/collector/pkg/rmid"
+)
+
+// Stream message types. A Stream-compatible BPF producer emits every event
+// it cares about as a PERF_RECORD_SAMPLE, matching how bpf_perf_event_output
+// always tags its output as a sample record; Stream tells events apart by
+// the msgType field embedded right after the timestamp Reader already
+// expects at the start of every sample payload (see
+// Reader.maintainHeapEntry).
+const (
+	streamMsgSample    uint32 = 0
+	streamMsgRMIDAlloc uint32 = 1
+	streamMsgRMIDFree  uint32 = 2
+)
+
+// streamCommLen matches Linux's TASK_COMM_LEN.
+const streamCommLen = 16
+
+// streamHeaderLen is the size, in bytes, of the fields every Stream message
+// payload starts with: an 8-byte timestamp (also used by Reader for
+// cross-ring ordering), a 4-byte message type, and a 4-byte RMID.
+const streamHeaderLen = 8 + 4 + 4
+
+// DefaultQueueSize is the subscriber channel capacity used when
+// StreamOptions.QueueSize is zero.
+const DefaultQueueSize = 256
+
+// EventType identifies the kind of event a Stream subscriber receives.
+type EventType int
+
+const (
+	// EventSample is a regular performance sample, annotated with its RMID
+	// owner's comm as of the sample's timestamp.
+	EventSample EventType = iota
+	// EventLost indicates some records were lost before Stream could read
+	// them, either because the kernel reported PERF_RECORD_LOST or because
+	// PerfRing detected its own producer/consumer overrun. Dropped holds
+	// the lost count (records for the former, bytes for the latter).
+	EventLost
+	// EventRMIDAlloc indicates an RMID was (re)assigned to a new owner.
+	EventRMIDAlloc
+	// EventRMIDFree indicates an RMID's owner released it.
+	EventRMIDFree
+)
+
+// Event is a single enriched record delivered to a Stream subscriber.
+type Event struct {
+	Type      EventType
+	CPU       int
+	RMID      uint32
+	Timestamp uint64
+	// Comm is the RMID owner's process name, resolved via rmid.Tracker as of
+	// Timestamp. Set for EventSample and EventRMIDAlloc.
+	Comm string
+	// Payload is the message-specific remainder of an EventSample record,
+	// after the common timestamp/msgType/RMID header; nil for every other
+	// event type. It's a copy, safe to retain.
+	Payload []byte
+	// Dropped is the lost count, set only for EventLost.
+	Dropped uint64
+}
+
+// Filter selects which events a Stream subscriber receives. A zero Filter
+// matches everything; non-zero fields are ANDed together.
+type Filter struct {
+	// RMID, if set, matches only events for that RMID.
+	RMID *uint32
+	// Comm, if set, matches only events whose Comm it matches.
+	Comm *regexp.Regexp
+	// Types, if non-empty, matches only events of one of these types.
+	Types []EventType
+}
+
+func (f Filter) matches(e Event) bool {
+	if f.RMID != nil && *f.RMID != e.RMID {
+		return false
+	}
+	if f.Comm != nil && !f.Comm.MatchString(e.Comm) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// CancelFunc unsubscribes a Stream subscriber, closing its event channel.
+// It's safe to call more than once.
+type CancelFunc func()
+
+// streamSubscriber is a single Subscribe call's queue and filter.
+type streamSubscriber struct {
+	filter  Filter
+	ch      chan Event
+	dropped uint64 // atomic
+}
+
+// deliver enqueues e if it matches the subscriber's filter, dropping the
+// oldest queued event to make room if the channel is full.
+func (s *streamSubscriber) deliver(e Event) {
+	if !s.filter.matches(e) {
+		return
+	}
+	for {
+		select {
+		case s.ch <- e:
+			return
+		default:
+		}
+		select {
+		case <-s.ch:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+			// The consumer drained the channel between the two selects;
+			// loop around and try the send again.
+		}
+	}
+}
+
+// StreamOptions configures a Stream.
+type StreamOptions struct {
+	// QueueSize bounds each subscriber's channel; once full, Stream drops
+	// the oldest buffered event to make room for the newest one instead of
+	// blocking the read loop. Defaults to DefaultQueueSize if zero.
+	QueueSize int
+}
+
+// Stream runs a goroutine that drives a Reader, decodes each record into an
+// Event, advances an rmid.Tracker by the event's timestamp, joins the
+// resolved RMID owner's comm onto sample events, and fans the enriched
+// events out to subscribers. Use NewStream to create one and Close to stop
+// it.
+type Stream struct {
+	reader    *Reader
+	tracker   *rmid.Tracker
+	queueSize int
+
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewStream creates a Stream over reader, using tracker to resolve RMID
+// ownership, and starts its read loop in a background goroutine. Call Close
+// to stop it.
+func NewStream(reader *Reader, tracker *rmid.Tracker, opts StreamOptions) *Stream {
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	s := &Stream{
+		reader:      reader,
+		tracker:     tracker,
+		queueSize:   queueSize,
+		subscribers: make(map[*streamSubscriber]struct{}),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Subscribe registers a new subscriber matching filter and returns a channel
+// of enriched events along with a CancelFunc to unsubscribe. The channel is
+// closed when CancelFunc is called or once Close stops the Stream.
+func (s *Stream) Subscribe(filter Filter) (<-chan Event, CancelFunc) {
+	sub := &streamSubscriber{filter: filter, ch: make(chan Event, s.queueSize)}
+
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			_, ok := s.subscribers[sub]
+			delete(s.subscribers, sub)
+			s.mu.Unlock()
+			if ok {
+				close(sub.ch)
+			}
+		})
+	}
+	return sub.ch, CancelFunc(cancel)
+}
+
+// Dropped returns the number of events dropped for the subscriber owning ch
+// because its queue was full, or 0 if ch isn't a currently-subscribed
+// channel (including one that was never returned by Subscribe, or has since
+// been cancelled).
+func (s *Stream) Dropped(ch <-chan Event) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if (<-chan Event)(sub.ch) == ch {
+			return atomic.LoadUint64(&sub.dropped)
+		}
+	}
+	return 0
+}
+
+// Close stops the Stream's read loop and closes every remaining subscriber
+// channel.
+func (s *Stream) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		close(sub.ch)
+	}
+	s.subscribers = make(map[*streamSubscriber]struct{})
+	return nil
+}
+
+func (s *Stream) broadcast(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		sub.deliver(e)
+	}
+}
+
+// run drives the Reader in a Start/Read/Finish loop until Close is called.
+func (s *Stream) run() {
+	defer close(s.done)
+
+	var rec Record
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		if err := s.reader.Start(); err != nil {
+			// Either there's nothing to read yet, or a ring reported
+			// ErrLostData and needs an explicit Resync before Start will
+			// succeed again; either way, back off briefly and retry.
+			if errors.Is(err, ErrLostData) {
+				s.resyncLostRing()
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			continue
+		}
+
+		for !s.reader.Empty() {
+			select {
+			case <-s.stop:
+				s.reader.Finish()
+				return
+			default:
+			}
+
+			if err := s.reader.Read(&rec); err != nil {
+				if errors.Is(err, ErrLostData) {
+					s.resyncLostRing()
+					continue
+				}
+				break
+			}
+
+			s.handleRecord(&rec)
+		}
+
+		s.reader.Finish()
+	}
+}
+
+// resyncLostRing resyncs whichever ring is currently stuck after an
+// ErrLostData, and broadcasts an EventLost reporting how much was skipped.
+func (s *Stream) resyncLostRing() {
+	ring, err := s.reader.CurrentRing()
+	if err != nil {
+		// Start failed, so the reader isn't active and has no current ring.
+		// Resync is a no-op on rings that lost nothing, so resync them all.
+		for _, r := range s.reader.rings {
+			if lost := r.Resync(); lost > 0 {
+				s.broadcast(Event{Type: EventLost, Dropped: uint64(lost)})
+			}
+		}
+		return
+	}
+	if lost := ring.Resync(); lost > 0 {
+		s.broadcast(Event{Type: EventLost, Dropped: uint64(lost)})
+	}
+}
+
+// handleRecord decodes rec and advances tracker/subscribers accordingly.
+func (s *Stream) handleRecord(rec *Record) {
+	if rec.Type == PERF_RECORD_LOST {
+		s.broadcast(Event{
+			Type:      EventLost,
+			CPU:       rec.CPU,
+			Timestamp: rec.Timestamp,
+			Dropped:   lostRecordCount(rec.RawSample),
+		})
+		return
+	}
+
+	if rec.Type != PERF_RECORD_SAMPLE || len(rec.RawSample) < 4 {
+		return
+	}
+
+	// PerfRing.Write prefixes PERF_RECORD_SAMPLE payloads with a 4-byte size
+	// field (see Record.RawSample); skip it to reach our own message header.
+	data := rec.RawSample[4:]
+	if len(data) < streamHeaderLen {
+		return
+	}
+
+	msgType := binary.LittleEndian.Uint32(data[8:12])
+	id := binary.LittleEndian.Uint32(data[12:16])
+	payload := data[streamHeaderLen:]
+
+	switch msgType {
+	case streamMsgRMIDAlloc:
+		if len(payload) < streamCommLen {
+			return
+		}
+		comm := decodeComm(payload[:streamCommLen])
+		s.tracker.Alloc(id, comm, rec.Timestamp)
+		s.tracker.Advance(rec.Timestamp)
+		s.broadcast(Event{Type: EventRMIDAlloc, CPU: rec.CPU, RMID: id, Timestamp: rec.Timestamp, Comm: comm})
+	case streamMsgRMIDFree:
+		s.tracker.Free(id, rec.Timestamp)
+		s.tracker.Advance(rec.Timestamp)
+		s.broadcast(Event{Type: EventRMIDFree, CPU: rec.CPU, RMID: id, Timestamp: rec.Timestamp})
+	default:
+		s.tracker.Advance(rec.Timestamp)
+		comm := ""
+		if meta, ok := s.tracker.GetMetadata(id); ok {
+			comm = meta.Comm
+		}
+		s.broadcast(Event{
+			Type:      EventSample,
+			CPU:       rec.CPU,
+			RMID:      id,
+			Timestamp: rec.Timestamp,
+			Comm:      comm,
+			Payload:   append([]byte(nil), payload...),
+		})
+	}
+}
+
+// lostRecordCount parses a PERF_RECORD_LOST record's {id, lost} payload, as
+// written by the kernel (and read the same way in
+// cmd/sync_timer_benchmark's readLostCount).
+func lostRecordCount(raw []byte) uint64 {
+	if len(raw) < 16 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(raw[8:16])
+}
+
+// decodeComm trims a fixed-width, null-padded comm field (TASK_COMM_LEN) to
+// a Go string.
+func decodeComm(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,167 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/unvariance/collector/pkg/rmid"
+)
+
+// encodeStreamMessage builds a Stream wire-format payload:
+// timestamp(8) + msgType(4) + rmid(4) + body.
+func encodeStreamMessage(timestamp uint64, msgType, id uint32, body []byte) []byte {
+	buf := make([]byte, streamHeaderLen+len(body))
+	binary.LittleEndian.PutUint64(buf[0:8], timestamp)
+	binary.LittleEndian.PutUint32(buf[8:12], msgType)
+	binary.LittleEndian.PutUint32(buf[12:16], id)
+	copy(buf[streamHeaderLen:], body)
+	return buf
+}
+
+func encodeComm(name string) []byte {
+	buf := make([]byte, streamCommLen)
+	copy(buf, name)
+	return buf
+}
+
+func newStreamTestRing(t *testing.T) *PerfRing {
+	t.Helper()
+	pageSize := uint64(4096)
+	nPages := uint32(2)
+	data := make([]byte, pageSize*(1+uint64(nPages)))
+	ring, err := InitContiguous(data, nPages, pageSize)
+	if err != nil {
+		t.Fatalf("failed to create ring: %v", err)
+	}
+	return ring
+}
+
+func recvEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestStreamJoinsCommOntoSamples(t *testing.T) {
+	ring := newStreamTestRing(t)
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(encodeStreamMessage(100, streamMsgRMIDAlloc, 5, encodeComm("proc-a")), PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write alloc: %v", err)
+	}
+	if _, err := ring.Write(encodeStreamMessage(200, streamMsgSample, 5, []byte("payload")), PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	if _, err := ring.Write(encodeStreamMessage(300, streamMsgRMIDFree, 5, nil), PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write free: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	tracker := rmid.NewTracker()
+	stream := NewStream(reader, tracker, StreamOptions{})
+	defer stream.Close()
+
+	ch, cancel := stream.Subscribe(Filter{})
+	defer cancel()
+
+	alloc := recvEvent(t, ch)
+	if alloc.Type != EventRMIDAlloc || alloc.RMID != 5 || alloc.Comm != "proc-a" {
+		t.Errorf("unexpected alloc event: %+v", alloc)
+	}
+
+	sample := recvEvent(t, ch)
+	if sample.Type != EventSample || sample.RMID != 5 || sample.Comm != "proc-a" {
+		t.Errorf("unexpected sample event: %+v", sample)
+	}
+	if !bytes.HasPrefix(sample.Payload, []byte("payload")) {
+		t.Errorf("expected payload to start with %q, got %q", "payload", sample.Payload)
+	}
+
+	free := recvEvent(t, ch)
+	if free.Type != EventRMIDFree || free.RMID != 5 {
+		t.Errorf("unexpected free event: %+v", free)
+	}
+}
+
+func TestStreamFilterByType(t *testing.T) {
+	ring := newStreamTestRing(t)
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	ring.StartWriteBatch()
+	if _, err := ring.Write(encodeStreamMessage(100, streamMsgRMIDAlloc, 1, encodeComm("proc-b")), PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write alloc: %v", err)
+	}
+	if _, err := ring.Write(encodeStreamMessage(200, streamMsgSample, 1, nil), PERF_RECORD_SAMPLE); err != nil {
+		t.Fatalf("failed to write sample: %v", err)
+	}
+	ring.FinishWriteBatch()
+
+	tracker := rmid.NewTracker()
+	stream := NewStream(reader, tracker, StreamOptions{})
+	defer stream.Close()
+
+	ch, cancel := stream.Subscribe(Filter{Types: []EventType{EventSample}})
+	defer cancel()
+
+	sample := recvEvent(t, ch)
+	if sample.Type != EventSample || sample.RMID != 1 {
+		t.Errorf("expected a sample event, got %+v", sample)
+	}
+}
+
+func TestStreamSubscriberDropsOldestWhenFull(t *testing.T) {
+	ring := newStreamTestRing(t)
+	reader := NewReader()
+	if err := reader.AddRing(ring); err != nil {
+		t.Fatalf("failed to add ring: %v", err)
+	}
+
+	const n = 10
+	ring.StartWriteBatch()
+	for i := 0; i < n; i++ {
+		if _, err := ring.Write(encodeStreamMessage(uint64(100+i), streamMsgSample, 1, nil), PERF_RECORD_SAMPLE); err != nil {
+			t.Fatalf("failed to write sample %d: %v", i, err)
+		}
+	}
+	ring.FinishWriteBatch()
+
+	tracker := rmid.NewTracker()
+	stream := NewStream(reader, tracker, StreamOptions{QueueSize: 2})
+	defer stream.Close()
+
+	ch, cancel := stream.Subscribe(Filter{})
+	defer cancel()
+
+	// Give the read loop a chance to run well ahead of this slow consumer.
+	time.Sleep(50 * time.Millisecond)
+
+	var last Event
+	for {
+		select {
+		case last = <-ch:
+		default:
+			goto drained
+		}
+	}
+drained:
+	if last.Timestamp != uint64(100+n-1) {
+		t.Errorf("expected the newest sample to survive, got timestamp %d", last.Timestamp)
+	}
+	if dropped := stream.Dropped(ch); dropped == 0 {
+		t.Error("expected some events to have been dropped")
+	}
+}
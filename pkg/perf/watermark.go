@@ -0,0 +1,88 @@
+package perf
+
+// WatermarkController implements an AIMD controller for a perf ring's wakeup
+// watermark: it halves the watermark (down to a one-page floor) as soon as a
+// window reports lost samples, and additively increases it by one page once the
+// ring has stayed under a low fill threshold with no losses for enough
+// consecutive windows. Callers feed it per-window observations (e.g. once per
+// SetDeadline tick) and read back the watermark to apply on the next reader.
+type WatermarkController struct {
+	pageSize   uint32
+	bufferSize uint32
+	watermark  uint32
+
+	// windowsForIncrease is how many consecutive low-fill, loss-free windows are
+	// required before the watermark is additively increased.
+	windowsForIncrease int
+	stableWindows      int
+
+	acceptedTotal uint64
+	lostTotal     uint64
+}
+
+// lowFillThreshold is the fraction of the buffer that must remain free across a
+// window before it counts toward an additive increase.
+const lowFillThreshold = 0.25
+
+// NewWatermarkController creates a controller for a ring with the given page
+// size and total buffer size in bytes, starting at initialWatermark and
+// requiring windowsForIncrease consecutive good windows before growing.
+func NewWatermarkController(pageSize, bufferSize, initialWatermark uint32, windowsForIncrease int) *WatermarkController {
+	if initialWatermark < pageSize {
+		initialWatermark = pageSize
+	}
+	return &WatermarkController{
+		pageSize:           pageSize,
+		bufferSize:         bufferSize,
+		watermark:          initialWatermark,
+		windowsForIncrease: windowsForIncrease,
+	}
+}
+
+// Observe records one window's worth of activity: the number of samples lost
+// and accepted during the window, and the number of bytes still free in the
+// ring at the time of observation. It updates the watermark in place.
+func (c *WatermarkController) Observe(lost, accepted uint64, bytesFree uint32) {
+	c.lostTotal += lost
+	c.acceptedTotal += accepted
+
+	if lost > 0 {
+		c.watermark /= 2
+		if c.watermark < c.pageSize {
+			c.watermark = c.pageSize
+		}
+		c.stableWindows = 0
+		return
+	}
+
+	if c.bufferSize == 0 || float64(bytesFree) < lowFillThreshold*float64(c.bufferSize) {
+		c.stableWindows = 0
+		return
+	}
+
+	c.stableWindows++
+	if c.stableWindows >= c.windowsForIncrease {
+		c.watermark += c.pageSize
+		if c.watermark > c.bufferSize {
+			c.watermark = c.bufferSize
+		}
+		c.stableWindows = 0
+	}
+}
+
+// Watermark returns the current watermark in bytes.
+func (c *WatermarkController) Watermark() uint32 {
+	return c.watermark
+}
+
+// LostAcceptedRatio returns the running ratio of lost to accepted samples over
+// the controller's lifetime, for exposing as a gauge alongside the watermark.
+func (c *WatermarkController) LostAcceptedRatio() float64 {
+	if c.acceptedTotal == 0 {
+		if c.lostTotal > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(c.lostTotal) / float64(c.acceptedTotal)
+}
@@ -0,0 +1,78 @@
+package perf
+
+import "testing"
+
+func TestWatermarkControllerHalvesOnLoss(t *testing.T) {
+	c := NewWatermarkController(4096, 65536, 16384, 4)
+
+	c.Observe(3, 100, 40000)
+	if got := c.Watermark(); got != 8192 {
+		t.Fatalf("expected watermark to halve to 8192, got %d", got)
+	}
+
+	c.Observe(1, 100, 40000)
+	if got := c.Watermark(); got != 4096 {
+		t.Fatalf("expected watermark to halve to 4096, got %d", got)
+	}
+}
+
+func TestWatermarkControllerFloorsAtOnePage(t *testing.T) {
+	c := NewWatermarkController(4096, 65536, 4096, 4)
+
+	c.Observe(1, 100, 40000)
+	if got := c.Watermark(); got != 4096 {
+		t.Fatalf("expected watermark to stay at the one-page floor, got %d", got)
+	}
+}
+
+func TestWatermarkControllerAdditiveIncreaseAfterStableWindows(t *testing.T) {
+	c := NewWatermarkController(4096, 65536, 8192, 3)
+
+	// Low fill, no losses, for two windows: shouldn't increase yet.
+	c.Observe(0, 100, 60000)
+	c.Observe(0, 100, 60000)
+	if got := c.Watermark(); got != 8192 {
+		t.Fatalf("expected no increase before the third stable window, got %d", got)
+	}
+
+	// Third consecutive stable window triggers the increase.
+	c.Observe(0, 100, 60000)
+	if got := c.Watermark(); got != 12288 {
+		t.Fatalf("expected watermark to grow by one page to 12288, got %d", got)
+	}
+}
+
+func TestWatermarkControllerHighFillDoesNotIncrease(t *testing.T) {
+	c := NewWatermarkController(4096, 65536, 8192, 2)
+
+	// bytesFree well below lowFillThreshold of the buffer (i.e. the ring is
+	// more than 75% full), so stability shouldn't accumulate.
+	for i := 0; i < 5; i++ {
+		c.Observe(0, 100, 1000)
+	}
+	if got := c.Watermark(); got != 8192 {
+		t.Fatalf("expected watermark to stay at 8192 while fill is high, got %d", got)
+	}
+}
+
+func TestWatermarkControllerCapsAtBufferSize(t *testing.T) {
+	c := NewWatermarkController(4096, 8192, 8192, 1)
+
+	c.Observe(0, 100, 8192)
+	if got := c.Watermark(); got != 8192 {
+		t.Fatalf("expected watermark capped at buffer size 8192, got %d", got)
+	}
+}
+
+func TestWatermarkControllerLostAcceptedRatio(t *testing.T) {
+	c := NewWatermarkController(4096, 65536, 8192, 4)
+
+	if got := c.LostAcceptedRatio(); got != 0 {
+		t.Fatalf("expected 0 ratio with no observations, got %f", got)
+	}
+
+	c.Observe(5, 95, 40000)
+	if got := c.LostAcceptedRatio(); got != 5.0/95.0 {
+		t.Fatalf("expected ratio %f, got %f", 5.0/95.0, got)
+	}
+}
@@ -0,0 +1,25 @@
+package perf_ebpf
+
+import "github.com/unvariance/collector/pkg/perf"
+
+// EventReader is implemented by both PerfMapReader (a PERF_EVENT_ARRAY of
+// per-CPU mmap rings) and RingBufMapReader (a single BPF_MAP_TYPE_RINGBUF),
+// so a loader can pick whichever backend the running kernel supports -
+// RingBufMapReader on kernels new enough for BPF_MAP_TYPE_RINGBUF (>= 5.8),
+// falling back to PerfMapReader otherwise - without the rest of the
+// pipeline needing to know which one it got.
+type EventReader interface {
+	// Read blocks until the next event is available, or the reader is
+	// closed, and decodes it into rec.
+	Read(rec *perf.Record) error
+	// LostCount returns the total number of events known to have been
+	// dropped before being read.
+	LostCount() uint64
+	// Close releases every resource the reader holds.
+	Close() error
+}
+
+var (
+	_ EventReader = (*PerfMapReader)(nil)
+	_ EventReader = (*RingBufMapReader)(nil)
+)
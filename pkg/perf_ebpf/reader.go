@@ -3,9 +3,12 @@ package perf_ebpf
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/cilium/ebpf"
 	"github.com/unvariance/collector/pkg/perf"
+	"github.com/unvariance/collector/pkg/runtimetune"
+	"golang.org/x/sys/unix"
 )
 
 // Options controls the behavior of PerfMapReader
@@ -15,14 +18,29 @@ type Options struct {
 	// The number of bytes that must be written before waking up userspace
 	// Must be less than BufferSize
 	WatermarkBytes uint32
+	// CPUSet restricts which CPUs get a ring allocated. If nil, it's
+	// auto-detected from the process's scheduling affinity intersected with
+	// the cgroup's cpuset controller (see runtimetune.EffectiveCPUs), so a
+	// container or systemd unit with a narrow cpuset doesn't pay for rings on
+	// CPUs it can never be scheduled on.
+	CPUSet []int
 }
 
 // PerfMapReader manages perf ring buffers connected to an eBPF map
 type PerfMapReader struct {
-	array   *ebpf.Map
-	rings   []*perf.PerfRing
-	storage []*perf.MmapRingStorage
-	reader  *perf.Reader
+	array     *ebpf.Map
+	cpus      []int
+	rings     []*perf.PerfRing
+	storage   []*perf.MmapRingStorage
+	reader    *perf.Reader
+	lostCount uint64
+
+	// epollFd watches every ring's perf event fd, so Read can block until
+	// one of them has new data instead of busy-polling.
+	epollFd int
+	// batchActive tracks whether reader is between a Start() and a
+	// Finish() - i.e. mid read-batch - across successive Read calls.
+	batchActive bool
 }
 
 // NewPerfMapReader creates a new reader connected to an eBPF map
@@ -45,14 +63,21 @@ func NewPerfMapReader(array *ebpf.Map, opts Options) (*PerfMapReader, error) {
 		return nil, fmt.Errorf("invalid number of CPUs in map: %d", nCPU)
 	}
 
+	cpus, err := resolveCPUSet(opts.CPUSet, nCPU)
+	if err != nil {
+		return nil, err
+	}
+
 	pmr := &PerfMapReader{
 		array:   array,
-		rings:   make([]*perf.PerfRing, 0, nCPU),
-		storage: make([]*perf.MmapRingStorage, 0, nCPU),
+		cpus:    cpus,
+		rings:   make([]*perf.PerfRing, 0, len(cpus)),
+		storage: make([]*perf.MmapRingStorage, 0, len(cpus)),
+		epollFd: -1,
 	}
 
 	// Create storage and rings for each CPU
-	for cpu := 0; cpu < nCPU; cpu++ {
+	for _, cpu := range cpus {
 		storage, err := perf.NewMmapRingStorage(cpu, uint32(opts.BufferSize/4096), opts.WatermarkBytes)
 		if err != nil {
 			pmr.Close()
@@ -68,7 +93,7 @@ func NewPerfMapReader(array *ebpf.Map, opts Options) (*PerfMapReader, error) {
 		pmr.rings = append(pmr.rings, ring)
 
 		// Store the file descriptor in the eBPF map
-		if err := array.Put(uint32(cpu), storage.FileDescriptor()); err != nil {
+		if err := array.Put(uint32(cpu), uint32(storage.FileDescriptor())); err != nil {
 			pmr.Close()
 			return nil, fmt.Errorf("failed to update map for CPU %d: %w", cpu, err)
 		}
@@ -84,14 +109,134 @@ func NewPerfMapReader(array *ebpf.Map, opts Options) (*PerfMapReader, error) {
 	}
 	pmr.reader = reader
 
+	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		pmr.Close()
+		return nil, fmt.Errorf("epoll_create1 failed: %w", err)
+	}
+	pmr.epollFd = epollFd
+	for _, storage := range pmr.storage {
+		event := unix.EpollEvent{Events: unix.EPOLLIN}
+		event.Fd = int32(storage.FileDescriptor())
+		if err := unix.EpollCtl(epollFd, unix.EPOLL_CTL_ADD, storage.FileDescriptor(), &event); err != nil {
+			pmr.Close()
+			return nil, fmt.Errorf("epoll_ctl failed: %w", err)
+		}
+	}
+
 	return pmr, nil
 }
 
+// Read blocks, waking via epoll whenever one of the underlying per-CPU
+// rings has new data, until it can decode the next event (in timestamp
+// order across rings) into rec.
+func (pmr *PerfMapReader) Read(rec *perf.Record) error {
+	for {
+		if !pmr.batchActive {
+			if err := pmr.reader.Start(); err != nil {
+				return err
+			}
+			pmr.batchActive = true
+		}
+
+		if !pmr.reader.Empty() {
+			return pmr.reader.Read(rec)
+		}
+
+		if err := pmr.reader.Finish(); err != nil {
+			return err
+		}
+		pmr.batchActive = false
+
+		if err := pmr.waitForData(); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForData blocks until epoll reports at least one watched ring fd is
+// readable, respecting each ring's configured watermark (the kernel only
+// signals readiness once WatermarkBytes have accumulated, or on every
+// event if WatermarkBytes was 0).
+func (pmr *PerfMapReader) waitForData() error {
+	var events [1]unix.EpollEvent
+	for {
+		_, err := unix.EpollWait(pmr.epollFd, events[:], -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("epoll_wait failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// resolveCPUSet validates an explicit CPUSet against the map's CPU count, or
+// auto-detects one from runtimetune.EffectiveCPUs when cpuSet is nil. The
+// result is sorted and deduplicated so CPUs() and the rings/storage slices
+// it drives stay in a stable, predictable order.
+func resolveCPUSet(cpuSet []int, nCPU int) ([]int, error) {
+	if cpuSet == nil {
+		detected, err := runtimetune.EffectiveCPUs()
+		if err != nil {
+			return nil, fmt.Errorf("detecting effective CPUs: %w", err)
+		}
+		cpuSet = detected
+	}
+
+	seen := make(map[int]bool, len(cpuSet))
+	cpus := make([]int, 0, len(cpuSet))
+	for _, cpu := range cpuSet {
+		if cpu < 0 || cpu >= nCPU {
+			return nil, fmt.Errorf("CPU %d is out of range for map with %d entries", cpu, nCPU)
+		}
+		if seen[cpu] {
+			continue
+		}
+		seen[cpu] = true
+		cpus = append(cpus, cpu)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no CPUs to allocate rings for")
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// CPUs returns the CPUs this reader allocated a ring for, in the same order
+// as the rings returned by Reader's ring iteration, so callers can attribute
+// a sample's ring back to the CPU it came from.
+func (pmr *PerfMapReader) CPUs() []int {
+	return pmr.cpus
+}
+
 // Reader returns the underlying perf.Reader
 func (pmr *PerfMapReader) Reader() *perf.Reader {
 	return pmr.reader
 }
 
+// HandleLostRecord decodes ring's current PERF_RECORD_LOST record, adds it
+// to the reader's running LostCount, and returns the number of samples it
+// reported lost. It doesn't consume the record; callers still need to call
+// Reader().Pop (or equivalent) afterwards.
+func (pmr *PerfMapReader) HandleLostRecord(ring *perf.PerfRing) (uint64, error) {
+	lr, err := ring.DecodeLost()
+	if err != nil {
+		return 0, err
+	}
+	pmr.lostCount += lr.Lost
+	return lr.Lost, nil
+}
+
+// LostCount returns the total number of samples reported lost across every
+// PERF_RECORD_LOST record HandleLostRecord has processed, so callers like
+// the aggregator can attribute them in completed output rather than
+// silently under-counting.
+func (pmr *PerfMapReader) LostCount() uint64 {
+	return pmr.lostCount
+}
+
 // Close releases all resources
 func (pmr *PerfMapReader) Close() error {
 	if pmr.reader != nil {
@@ -104,11 +249,18 @@ func (pmr *PerfMapReader) Close() error {
 		}
 	}
 
+	if pmr.epollFd != -1 {
+		unix.Close(pmr.epollFd)
+		pmr.epollFd = -1
+	}
+
 	// Clear references
 	pmr.rings = nil
 	pmr.storage = nil
 	pmr.reader = nil
 	pmr.array = nil
+	pmr.cpus = nil
+	pmr.batchActive = false
 
 	return nil
 }
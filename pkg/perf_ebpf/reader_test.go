@@ -7,6 +7,58 @@ import (
 	"github.com/cilium/ebpf/rlimit"
 )
 
+func TestResolveCPUSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpuSet  []int
+		nCPU    int
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "explicit set is sorted and deduplicated",
+			cpuSet: []int{3, 1, 1, 2},
+			nCPU:   4,
+			want:   []int{1, 2, 3},
+		},
+		{
+			name:    "out of range CPU",
+			cpuSet:  []int{0, 4},
+			nCPU:    4,
+			wantErr: true,
+		},
+		{
+			name:    "empty explicit set",
+			cpuSet:  []int{},
+			nCPU:    4,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCPUSet(tt.cpuSet, tt.nCPU)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
 func TestNewPerfMapReader(t *testing.T) {
 	// Create a test eBPF map
 	mapSpec := &ebpf.MapSpec{
@@ -27,6 +79,11 @@ func TestNewPerfMapReader(t *testing.T) {
 	}
 	defer array.Close()
 
+	// PerfEventArray maps are sized to the number of possible CPUs
+	// regardless of MaxEntries, so the CPUSet test cases below size
+	// themselves off the map's actual entry count instead of assuming 4.
+	nCPU := int(array.MaxEntries())
+
 	tests := []struct {
 		name    string
 		array   *ebpf.Map
@@ -57,6 +114,18 @@ func TestNewPerfMapReader(t *testing.T) {
 			opts:    Options{BufferSize: 4096, WatermarkBytes: 1024},
 			wantErr: false,
 		},
+		{
+			name:    "explicit CPUSet out of range",
+			array:   array,
+			opts:    Options{BufferSize: 4096, WatermarkBytes: 1024, CPUSet: []int{0, nCPU}},
+			wantErr: true,
+		},
+		{
+			name:    "explicit CPUSet restricts allocation",
+			array:   array,
+			opts:    Options{BufferSize: 4096, WatermarkBytes: 1024, CPUSet: []int{0}},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -78,6 +147,12 @@ func TestNewPerfMapReader(t *testing.T) {
 			if reader.Reader() == nil {
 				t.Error("expected non-nil reader")
 			}
+
+			if tt.opts.CPUSet != nil {
+				if got := reader.CPUs(); len(got) != len(tt.opts.CPUSet) {
+					t.Errorf("expected CPUs() to report %v, got %v", tt.opts.CPUSet, got)
+				}
+			}
 		})
 	}
 }
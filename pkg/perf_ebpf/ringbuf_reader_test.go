@@ -0,0 +1,64 @@
+package perf_ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+func TestNewRingBufMapReader(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Fatalf("failed to remove memlock: %v", err)
+	}
+
+	ringBufMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.RingBuf,
+		MaxEntries: 4096,
+	})
+	if err != nil {
+		t.Fatalf("failed to create ringbuf map: %v", err)
+	}
+	defer ringBufMap.Close()
+
+	perfArrayMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.PerfEventArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatalf("failed to create perf event array map: %v", err)
+	}
+	defer perfArrayMap.Close()
+
+	tests := []struct {
+		name    string
+		array   *ebpf.Map
+		wantErr bool
+	}{
+		{name: "nil array", array: nil, wantErr: true},
+		{name: "wrong map type", array: perfArrayMap, wantErr: true},
+		{name: "valid ringbuf map", array: ringBufMap, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewRingBufMapReader(tt.array, RingBufOptions{WatermarkBytes: 1024})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer reader.Close()
+
+			if reader.LostCount() != 0 {
+				t.Errorf("expected LostCount to start at 0, got %d", reader.LostCount())
+			}
+		})
+	}
+}
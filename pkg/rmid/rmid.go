@@ -2,6 +2,11 @@
 package rmid
 
 import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
 	"sort"
 )
 
@@ -15,10 +20,12 @@ const (
 	MessageTypeFree MessageType = 2
 )
 
-// Metadata represents the metadata associated with an RMID
+// Metadata represents the metadata associated with an RMID as of the most
+// recently applied event.
 type Metadata struct {
-	Comm  string // Process command name
-	Valid bool   // Whether the RMID is currently valid
+	Comm       string // Process command name
+	Valid      bool   // Whether the RMID is currently valid
+	Generation uint64 // Generation assigned when this allocation was applied
 }
 
 // Message represents a timestamped RMID update
@@ -29,22 +36,88 @@ type Message struct {
 	Timestamp uint64
 }
 
+// openFreeTS marks a generationEntry whose RMID hasn't been freed yet.
+const openFreeTS = math.MaxUint64
+
+// generationEntry records one allocation lifetime of an RMID, so that
+// LookupAt can resolve the owner of an RMID at a timestamp that predates a
+// later reuse of the same RMID.
+type generationEntry struct {
+	Generation uint64
+	Comm       string
+	AllocTS    uint64
+	FreeTS     uint64
+}
+
+// EvictionFunc is called with an RMID's final metadata when capacity
+// eviction forgets it, so a downstream aggregator (e.g. one keeping
+// per-RMID metrics) can flush it before it becomes unreachable via
+// GetMetadata.
+type EvictionFunc func(rmid uint32, meta Metadata)
+
 // Tracker maintains the state of RMID allocations and frees
 type Tracker struct {
 	// Current state of RMIDs
 	rmids map[uint32]Metadata
 	// Queue of pending updates
 	updates []Message
+	// history holds one generationEntry per allocation lifetime of each
+	// RMID, oldest first, so LookupAt can binary-search it by timestamp.
+	history map[uint32][]generationEntry
+	// nextGeneration is handed out, then incremented, each time an Alloc is
+	// applied, so generations are monotonically increasing across all RMIDs.
+	nextGeneration uint64
+	// lastAdvance is the timestamp Advance most recently processed up to;
+	// GC uses it as "now" when comparing against a retention window.
+	lastAdvance uint64
+
+	// capacity bounds len(rmids); 0 means unbounded (no eviction). Set via
+	// NewBoundedTracker.
+	capacity int
+	// evictionRetention is how long, relative to lastAdvance, a Freed RMID
+	// must have gone untouched before it becomes eligible for eviction.
+	evictionRetention uint64
+	// onEvict is called for each RMID capacity eviction forgets.
+	onEvict EvictionFunc
+	// lru orders every tracked RMID from least to most recently touched by
+	// Advance, so eviction has a cheap place to start looking for the
+	// oldest eligible (Freed and aged out) entry.
+	lru *list.List
+	// lruElem is each RMID's element in lru, so touch can move it to the
+	// back in O(1) instead of searching the list.
+	lruElem map[uint32]*list.Element
+	// lastTouch is the timestamp of the most recent message applied for
+	// each RMID, used to measure how long a Freed RMID has gone untouched.
+	lastTouch map[uint32]uint64
 }
 
-// NewTracker creates a new RMID tracker
+// NewTracker creates a new RMID tracker with no eviction: rmids grows
+// without bound, exactly as before this field existed. Use NewBoundedTracker
+// to cap memory growth on long-running processes with high RMID churn.
 func NewTracker() *Tracker {
 	return &Tracker{
-		rmids:   make(map[uint32]Metadata),
-		updates: make([]Message, 0),
+		rmids:     make(map[uint32]Metadata),
+		updates:   make([]Message, 0),
+		history:   make(map[uint32][]generationEntry),
+		lru:       list.New(),
+		lruElem:   make(map[uint32]*list.Element),
+		lastTouch: make(map[uint32]uint64),
 	}
 }
 
+// NewBoundedTracker creates a Tracker that, once more than capacity RMIDs
+// are tracked, evicts Freed RMIDs that have gone untouched for at least
+// retention (measured against the timestamp passed to Advance/AdvanceChanged)
+// in least-recently-touched order. onEvict, if non-nil, is called with each
+// evicted RMID's final metadata before it's forgotten.
+func NewBoundedTracker(capacity int, retention uint64, onEvict EvictionFunc) *Tracker {
+	t := NewTracker()
+	t.capacity = capacity
+	t.evictionRetention = retention
+	t.onEvict = onEvict
+	return t
+}
+
 // Alloc enqueues an RMID allocation with metadata
 func (t *Tracker) Alloc(rmid uint32, comm string, timestamp uint64) {
 	meta := Metadata{
@@ -75,6 +148,17 @@ func (t *Tracker) Free(rmid uint32, timestamp uint64) {
 
 // Advance processes queued events up to the given timestamp
 func (t *Tracker) Advance(timestamp uint64) {
+	t.AdvanceChanged(timestamp)
+}
+
+// AdvanceChanged behaves like Advance, but additionally returns every RMID
+// whose Valid metadata flag changed while processing this interval (sorted
+// ascending), so callers can react to exactly the RMIDs that changed instead
+// of polling GetMetadata for every known RMID. An RMID that both transitions
+// and then is capacity-evicted within the same call is still reported here;
+// its eviction has already been delivered to onEvict by the time this
+// returns, so a subsequent GetMetadata for it will report it doesn't exist.
+func (t *Tracker) AdvanceChanged(timestamp uint64) []uint32 {
 	// Updates are sorted by timestamp
 
 	// Find index of first update beyond timestamp using safe arithmetic
@@ -82,23 +166,115 @@ func (t *Tracker) Advance(timestamp uint64) {
 		return (t.updates[i].Timestamp - timestamp) < (1 << 63)
 	})
 
+	changedSet := make(map[uint32]struct{})
+
 	// Process updates up to timestamp
 	for _, msg := range t.updates[:splitIdx] {
 		switch msg.Type {
 		case MessageTypeAlloc:
-			// Update metadata
-			t.rmids[msg.RMID] = msg.Metadata
+			prev, existed := t.rmids[msg.RMID]
+
+			gen := t.nextGeneration
+			t.nextGeneration++
+
+			meta := msg.Metadata
+			meta.Generation = gen
+			t.rmids[msg.RMID] = meta
+
+			t.history[msg.RMID] = append(t.history[msg.RMID], generationEntry{
+				Generation: gen,
+				Comm:       meta.Comm,
+				AllocTS:    msg.Timestamp,
+				FreeTS:     openFreeTS,
+			})
+
+			if !existed || !prev.Valid {
+				changedSet[msg.RMID] = struct{}{}
+			}
+			t.touch(msg.RMID, msg.Timestamp)
 		case MessageTypeFree:
 			// Mark RMID as invalid but preserve metadata
 			if meta, exists := t.rmids[msg.RMID]; exists {
+				wasValid := meta.Valid
 				meta.Valid = false
 				t.rmids[msg.RMID] = meta
+
+				if entries := t.history[msg.RMID]; len(entries) > 0 {
+					if last := &entries[len(entries)-1]; last.Generation == meta.Generation {
+						last.FreeTS = msg.Timestamp
+					}
+				}
+
+				if wasValid {
+					changedSet[msg.RMID] = struct{}{}
+				}
+				t.touch(msg.RMID, msg.Timestamp)
 			}
 		}
 	}
 
 	// Remove processed updates
 	t.updates = t.updates[splitIdx:]
+
+	if timestamp > t.lastAdvance {
+		t.lastAdvance = timestamp
+	}
+
+	t.evict()
+
+	changed := make([]uint32, 0, len(changedSet))
+	for rmid := range changedSet {
+		changed = append(changed, rmid)
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	return changed
+}
+
+// touch records timestamp as rmid's most recent update and moves it to the
+// back of the LRU list (the end eviction considers last).
+func (t *Tracker) touch(rmid uint32, timestamp uint64) {
+	t.lastTouch[rmid] = timestamp
+	if elem, ok := t.lruElem[rmid]; ok {
+		t.lru.MoveToBack(elem)
+	} else {
+		t.lruElem[rmid] = t.lru.PushBack(rmid)
+	}
+}
+
+// evict forgets Freed, long-untouched RMIDs in least-recently-touched order
+// until len(rmids) is back within capacity, or no remaining tracked RMID is
+// eligible (evict never forgets a currently-Valid RMID, regardless of
+// capacity pressure).
+func (t *Tracker) evict() {
+	if t.capacity <= 0 {
+		return
+	}
+
+	for len(t.rmids) > t.capacity {
+		elem := t.lru.Front()
+		evicted := false
+		for elem != nil {
+			rmid := elem.Value.(uint32)
+			next := elem.Next()
+
+			meta, exists := t.rmids[rmid]
+			if exists && !meta.Valid && t.lastAdvance-t.lastTouch[rmid] >= t.evictionRetention {
+				if t.onEvict != nil {
+					t.onEvict(rmid, meta)
+				}
+				delete(t.rmids, rmid)
+				delete(t.lastTouch, rmid)
+				delete(t.lruElem, rmid)
+				t.lru.Remove(elem)
+				evicted = true
+				break
+			}
+			elem = next
+		}
+		if !evicted {
+			return
+		}
+	}
 }
 
 // GetMetadata returns the metadata for an RMID
@@ -116,8 +292,273 @@ func (t *Tracker) GetAllMetadata() map[uint32]Metadata {
 	return result
 }
 
-// Reset clears all state and pending updates
+// LookupAt returns the metadata that was active for rmid at timestamp ts,
+// resolving reused RMIDs to whichever generation owned them at that time.
+// It returns false if no generation of rmid covers ts, e.g. ts predates the
+// first allocation Advance has applied, or was evicted by GC.
+func (t *Tracker) LookupAt(rmid uint32, ts uint64) (Metadata, bool) {
+	entries := t.history[rmid]
+	if len(entries) == 0 {
+		return Metadata{}, false
+	}
+
+	// entries is in allocation order, hence also non-decreasing AllocTS
+	// order; find the last entry whose AllocTS <= ts.
+	idx := sort.Search(len(entries), func(i int) bool {
+		return entries[i].AllocTS > ts
+	}) - 1
+	if idx < 0 {
+		return Metadata{}, false
+	}
+
+	entry := entries[idx]
+	if ts >= entry.FreeTS {
+		return Metadata{}, false
+	}
+
+	return Metadata{
+		Comm:       entry.Comm,
+		Valid:      entry.FreeTS == openFreeTS,
+		Generation: entry.Generation,
+	}, true
+}
+
+// GC evicts generations of every RMID that were freed more than retention
+// before the timestamp of the most recent Advance call. Generations that are
+// still open (never freed) are never evicted, so LookupAt keeps resolving
+// ts values newer than the retention window even for long-lived RMIDs.
+func (t *Tracker) GC(retention uint64) {
+	if t.lastAdvance < retention {
+		return
+	}
+	cutoff := t.lastAdvance - retention
+
+	for rmid, entries := range t.history {
+		keep := 0
+		for keep < len(entries) && entries[keep].FreeTS != openFreeTS && entries[keep].FreeTS < cutoff {
+			keep++
+		}
+		switch {
+		case keep == 0:
+			// Nothing to evict.
+		case keep == len(entries):
+			delete(t.history, rmid)
+		default:
+			t.history[rmid] = append([]generationEntry(nil), entries[keep:]...)
+		}
+	}
+}
+
+// Reset clears all state and pending updates. It does not touch capacity,
+// evictionRetention, or onEvict, since those are configuration set once at
+// construction rather than accumulated state.
 func (t *Tracker) Reset() {
 	t.rmids = make(map[uint32]Metadata)
 	t.updates = t.updates[:0]
+	t.history = make(map[uint32][]generationEntry)
+	t.nextGeneration = 0
+	t.lastAdvance = 0
+	t.lru = list.New()
+	t.lruElem = make(map[uint32]*list.Element)
+	t.lastTouch = make(map[uint32]uint64)
+}
+
+// rmidSnapshotMagic identifies a stream as Tracker.Snapshot's on-disk format.
+const rmidSnapshotMagic = uint32(0x524d4944) // "RMID"
+
+// File format versions for Tracker.Snapshot.
+const (
+	// rmidSnapshotV1 persists nextGeneration, lastAdvance, the current rmids
+	// map, and any pending (not yet Advanced) updates.
+	rmidSnapshotV1 = uint8(1)
+)
+
+// Snapshot writes the tracker's current state to w in a versioned binary
+// format, so a restarting process can resume tracking without losing
+// knowledge of RMIDs allocated before the restart. It persists nextGeneration,
+// lastAdvance, the rmids map, and pending updates, but not history or the LRU
+// eviction bookkeeping - Restore approximates the latter from lastAdvance, so
+// a restored tracker's eviction behavior may differ slightly from one that
+// ran continuously. LookupAt has no data to resolve for timestamps before a
+// Restore.
+func (t *Tracker) Snapshot(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, rmidSnapshotMagic); err != nil {
+		return fmt.Errorf("writing magic: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, rmidSnapshotV1); err != nil {
+		return fmt.Errorf("writing version: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, t.nextGeneration); err != nil {
+		return fmt.Errorf("writing nextGeneration: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, t.lastAdvance); err != nil {
+		return fmt.Errorf("writing lastAdvance: %w", err)
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(t.rmids))); err != nil {
+		return fmt.Errorf("writing rmid count: %w", err)
+	}
+	for rmid, meta := range t.rmids {
+		if err := binary.Write(w, binary.LittleEndian, rmid); err != nil {
+			return fmt.Errorf("writing rmid %d: %w", rmid, err)
+		}
+		if err := writeSnapshotMetadata(w, meta); err != nil {
+			return fmt.Errorf("writing metadata for rmid %d: %w", rmid, err)
+		}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(t.updates))); err != nil {
+		return fmt.Errorf("writing update count: %w", err)
+	}
+	for _, msg := range t.updates {
+		if err := binary.Write(w, binary.LittleEndian, msg.Type); err != nil {
+			return fmt.Errorf("writing update type: %w", err)
+		}
+		if err := binary.Write(w, binary.LittleEndian, msg.RMID); err != nil {
+			return fmt.Errorf("writing update rmid: %w", err)
+		}
+		if err := binary.Write(w, binary.LittleEndian, msg.Timestamp); err != nil {
+			return fmt.Errorf("writing update timestamp: %w", err)
+		}
+		if err := writeSnapshotMetadata(w, msg.Metadata); err != nil {
+			return fmt.Errorf("writing update metadata: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Restore replaces the tracker's state with a snapshot previously written by
+// Snapshot. It discards any pending updates and history the tracker already
+// held. Restored RMIDs are seeded into the LRU eviction list as if they were
+// all last touched at the restored lastAdvance, since Snapshot doesn't
+// persist per-RMID touch times; a Freed RMID restored this way becomes
+// eviction-eligible evictionRetention after the restore, not after whenever
+// it was actually last touched before the snapshot was taken.
+func (t *Tracker) Restore(r io.Reader) error {
+	var magic uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return fmt.Errorf("reading magic: %w", err)
+	}
+	if magic != rmidSnapshotMagic {
+		return fmt.Errorf("not a rmid tracker snapshot: bad magic %#x", magic)
+	}
+
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return fmt.Errorf("reading version: %w", err)
+	}
+	if version != rmidSnapshotV1 {
+		return fmt.Errorf("unsupported rmid tracker snapshot version %d", version)
+	}
+
+	var nextGeneration, lastAdvance uint64
+	if err := binary.Read(r, binary.LittleEndian, &nextGeneration); err != nil {
+		return fmt.Errorf("reading nextGeneration: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &lastAdvance); err != nil {
+		return fmt.Errorf("reading lastAdvance: %w", err)
+	}
+
+	var rmidCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &rmidCount); err != nil {
+		return fmt.Errorf("reading rmid count: %w", err)
+	}
+	rmids := make(map[uint32]Metadata, rmidCount)
+	for i := uint32(0); i < rmidCount; i++ {
+		var rmid uint32
+		if err := binary.Read(r, binary.LittleEndian, &rmid); err != nil {
+			return fmt.Errorf("reading rmid: %w", err)
+		}
+		meta, err := readSnapshotMetadata(r)
+		if err != nil {
+			return fmt.Errorf("reading metadata for rmid %d: %w", rmid, err)
+		}
+		rmids[rmid] = meta
+	}
+
+	var updateCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &updateCount); err != nil {
+		return fmt.Errorf("reading update count: %w", err)
+	}
+	updates := make([]Message, 0, updateCount)
+	for i := uint32(0); i < updateCount; i++ {
+		var msg Message
+		if err := binary.Read(r, binary.LittleEndian, &msg.Type); err != nil {
+			return fmt.Errorf("reading update type: %w", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &msg.RMID); err != nil {
+			return fmt.Errorf("reading update rmid: %w", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &msg.Timestamp); err != nil {
+			return fmt.Errorf("reading update timestamp: %w", err)
+		}
+		meta, err := readSnapshotMetadata(r)
+		if err != nil {
+			return fmt.Errorf("reading update metadata: %w", err)
+		}
+		msg.Metadata = meta
+		updates = append(updates, msg)
+	}
+
+	t.rmids = rmids
+	t.updates = updates
+	t.history = make(map[uint32][]generationEntry)
+	t.nextGeneration = nextGeneration
+	t.lastAdvance = lastAdvance
+
+	t.lru = list.New()
+	t.lruElem = make(map[uint32]*list.Element)
+	t.lastTouch = make(map[uint32]uint64)
+	for rmid := range rmids {
+		t.lastTouch[rmid] = lastAdvance
+		t.lruElem[rmid] = t.lru.PushBack(rmid)
+	}
+
+	return nil
+}
+
+// writeSnapshotMetadata writes meta as Valid (1 byte), Generation (8 bytes),
+// then Comm length-prefixed by a uint16.
+func writeSnapshotMetadata(w io.Writer, meta Metadata) error {
+	valid := uint8(0)
+	if meta.Valid {
+		valid = 1
+	}
+	if err := binary.Write(w, binary.LittleEndian, valid); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, meta.Generation); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(meta.Comm))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(meta.Comm)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readSnapshotMetadata reads a Metadata written by writeSnapshotMetadata.
+func readSnapshotMetadata(r io.Reader) (Metadata, error) {
+	var valid uint8
+	if err := binary.Read(r, binary.LittleEndian, &valid); err != nil {
+		return Metadata{}, err
+	}
+	var meta Metadata
+	meta.Valid = valid != 0
+	if err := binary.Read(r, binary.LittleEndian, &meta.Generation); err != nil {
+		return Metadata{}, err
+	}
+	var commLen uint16
+	if err := binary.Read(r, binary.LittleEndian, &commLen); err != nil {
+		return Metadata{}, err
+	}
+	comm := make([]byte, commLen)
+	if _, err := io.ReadFull(r, comm); err != nil {
+		return Metadata{}, err
+	}
+	meta.Comm = string(comm)
+	return meta, nil
 }
@@ -1,6 +1,9 @@
 package rmid
 
 import (
+	"bytes"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -8,7 +11,7 @@ func TestTracker_Basic(t *testing.T) {
 	tracker := NewTracker()
 
 	// Test allocation
-	tracker.Alloc(1, "test1", 100, 1000)
+	tracker.Alloc(1, "test1", 1000)
 	tracker.Advance(1500)
 
 	meta, exists := tracker.GetMetadata(1)
@@ -21,9 +24,6 @@ func TestTracker_Basic(t *testing.T) {
 	if meta.Comm != "test1" {
 		t.Errorf("Expected comm 'test1', got '%s'", meta.Comm)
 	}
-	if meta.Tgid != 100 {
-		t.Errorf("Expected tgid 100, got %d", meta.Tgid)
-	}
 
 	// Test free
 	tracker.Free(1, 2000)
@@ -42,10 +42,10 @@ func TestTracker_FutureEvents(t *testing.T) {
 	tracker := NewTracker()
 
 	// Add events
-	tracker.Alloc(1, "test1", 100, 1000)
+	tracker.Alloc(1, "test1", 1000)
 	tracker.Free(1, 2000)
-	tracker.Alloc(1, "override1", 200, 3000) // Future event
-	tracker.Alloc(2, "test2", 300, 4000)     // Future event
+	tracker.Alloc(1, "override1", 3000) // Future event
+	tracker.Alloc(2, "test2", 4000)     // Future event
 
 	// Try to advance to timestamp before future event
 	tracker.Advance(2500)
@@ -61,9 +61,6 @@ func TestTracker_FutureEvents(t *testing.T) {
 	if meta.Comm != "test1" {
 		t.Errorf("Expected comm 'test1', got '%s'", meta.Comm)
 	}
-	if meta.Tgid != 100 {
-		t.Errorf("Expected tgid 100, got %d", meta.Tgid)
-	}
 
 	// Check that RMID 2 was not processed
 	_, exists = tracker.GetMetadata(2)
@@ -76,7 +73,7 @@ func TestTracker_Reset(t *testing.T) {
 	tracker := NewTracker()
 
 	// Add some events and process them
-	tracker.Alloc(1, "test1", 100, 1000)
+	tracker.Alloc(1, "test1", 1000)
 	tracker.Free(1, 2000)
 	tracker.Advance(2500)
 
@@ -92,7 +89,7 @@ func TestTracker_Reset(t *testing.T) {
 	}
 
 	// Verify we can add new events after reset
-	tracker.Alloc(2, "test2", 200, 3000)
+	tracker.Alloc(2, "test2", 3000)
 	tracker.Advance(3500)
 
 	meta, exists := tracker.GetMetadata(2)
@@ -108,7 +105,7 @@ func TestTracker_Reallocation(t *testing.T) {
 	tracker := NewTracker()
 
 	// Initial allocation
-	tracker.Alloc(1, "test1", 100, 1000)
+	tracker.Alloc(1, "test1", 1000)
 	tracker.Advance(1500)
 
 	// Free
@@ -116,7 +113,7 @@ func TestTracker_Reallocation(t *testing.T) {
 	tracker.Advance(2500)
 
 	// Reallocate same RMID
-	tracker.Alloc(1, "test2", 200, 3000)
+	tracker.Alloc(1, "test2", 3000)
 	tracker.Advance(3500)
 
 	// Check final state
@@ -130,7 +127,179 @@ func TestTracker_Reallocation(t *testing.T) {
 	if meta.Comm != "test2" {
 		t.Errorf("Expected comm 'test2', got '%s'", meta.Comm)
 	}
-	if meta.Tgid != 200 {
-		t.Errorf("Expected tgid 200, got %d", meta.Tgid)
+	if meta.Generation == 0 {
+		t.Error("Expected the reallocation to receive a new, nonzero generation")
+	}
+}
+
+func TestTracker_LookupAtResolvesReusedRMID(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Alloc(1, "first", 1000)
+	tracker.Free(1, 2000)
+	tracker.Alloc(1, "second", 3000)
+	tracker.Advance(3500)
+
+	// A timestamp within the first generation's lifetime should resolve to
+	// "first", even though RMID 1 now belongs to "second".
+	meta, ok := tracker.LookupAt(1, 1500)
+	if !ok {
+		t.Fatal("Expected a generation covering ts=1500")
+	}
+	if meta.Comm != "first" || meta.Valid {
+		t.Errorf("Expected {first, invalid}, got %+v", meta)
+	}
+
+	// A timestamp after the free but before the reallocation falls in a gap.
+	_, ok = tracker.LookupAt(1, 2500)
+	if ok {
+		t.Error("Expected no generation to cover ts=2500")
+	}
+
+	// A timestamp within the current generation should resolve to "second".
+	meta, ok = tracker.LookupAt(1, 3200)
+	if !ok {
+		t.Fatal("Expected a generation covering ts=3200")
+	}
+	if meta.Comm != "second" || !meta.Valid {
+		t.Errorf("Expected {second, valid}, got %+v", meta)
+	}
+
+	// A timestamp before the first allocation has no generation.
+	_, ok = tracker.LookupAt(1, 500)
+	if ok {
+		t.Error("Expected no generation to cover ts=500")
+	}
+}
+
+func TestTracker_GCEvictsOldGenerations(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Alloc(1, "first", 1000)
+	tracker.Free(1, 2000)
+	tracker.Alloc(1, "second", 3000)
+	tracker.Advance(10000)
+
+	// Retention of 100 relative to the last Advance(10000) evicts anything
+	// freed before ts=9900, which includes the first generation.
+	tracker.GC(100)
+
+	if _, ok := tracker.LookupAt(1, 1500); ok {
+		t.Error("Expected the first generation to have been evicted")
+	}
+	meta, ok := tracker.LookupAt(1, 3200)
+	if !ok || meta.Comm != "second" {
+		t.Errorf("Expected the open second generation to survive GC, got %+v, %v", meta, ok)
+	}
+}
+
+func TestTracker_BoundedEvictionOrder(t *testing.T) {
+	var evicted []uint32
+	tracker := NewBoundedTracker(2, 100, func(rmid uint32, meta Metadata) {
+		evicted = append(evicted, rmid)
+	})
+
+	// RMID 1 is freed early and ages past retention; RMID 2 stays valid; RMID
+	// 3 is freed but too recently touched to be evicted yet.
+	tracker.Alloc(1, "one", 1000)
+	tracker.Alloc(2, "two", 1000)
+	tracker.Advance(1000)
+
+	tracker.Free(1, 1100)
+	tracker.Advance(1100)
+
+	// Still within capacity (2 tracked), so nothing evicted yet.
+	if len(evicted) != 0 {
+		t.Fatalf("Expected no eviction yet, got %v", evicted)
+	}
+
+	// Allocating RMID 3 pushes us over capacity. RMID 1 was freed at 1100 and
+	// is now aged 1100 (>= 100 retention) relative to lastAdvance=2200, so it
+	// should be the one evicted - RMID 2 is still Valid and must survive.
+	tracker.Alloc(3, "three", 2000)
+	tracker.Advance(2200)
+
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Fatalf("Expected RMID 1 to be evicted, got %v", evicted)
+	}
+	if _, exists := tracker.GetMetadata(1); exists {
+		t.Error("Expected RMID 1 to no longer be tracked")
+	}
+	if _, exists := tracker.GetMetadata(2); !exists {
+		t.Error("Expected still-valid RMID 2 to survive eviction")
+	}
+	if _, exists := tracker.GetMetadata(3); !exists {
+		t.Error("Expected newly allocated RMID 3 to be tracked")
+	}
+
+	// Freeing RMID 3 immediately shouldn't evict it yet: it hasn't aged past
+	// retention relative to the timestamp we advance to.
+	tracker.Free(3, 2200)
+	tracker.Advance(2250)
+	if _, exists := tracker.GetMetadata(3); !exists {
+		t.Error("Expected recently-freed RMID 3 to survive eviction while under retention")
+	}
+}
+
+func TestTracker_AdvanceChangedAcrossReallocation(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Alloc(1, "first", 1000)
+	tracker.Free(1, 2000)
+	tracker.Alloc(1, "second", 3000)
+
+	changed := tracker.AdvanceChanged(3500)
+	if !reflect.DeepEqual(changed, []uint32{1}) {
+		t.Errorf("Expected [1] reported once despite two transitions, got %v", changed)
+	}
+
+	tracker.Alloc(2, "two", 4000)
+	tracker.Free(1, 4000)
+	changed = tracker.AdvanceChanged(4500)
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	if !reflect.DeepEqual(changed, []uint32{1, 2}) {
+		t.Errorf("Expected [1 2], got %v", changed)
+	}
+
+	// Nothing changed in this interval, so AdvanceChanged should report none.
+	changed = tracker.AdvanceChanged(5000)
+	if len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+}
+
+func TestTracker_SnapshotRestore(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Alloc(1, "first", 1000)
+	tracker.Free(1, 2000)
+	tracker.Alloc(1, "second", 3000)
+	tracker.Alloc(2, "two", 3000)
+	tracker.Advance(3500)
+
+	// Leave an unprocessed update pending across the snapshot.
+	tracker.Alloc(3, "pending", 9000)
+
+	var buf bytes.Buffer
+	if err := tracker.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := NewTracker()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	wantAll := tracker.GetAllMetadata()
+	gotAll := restored.GetAllMetadata()
+	if !reflect.DeepEqual(wantAll, gotAll) {
+		t.Errorf("Expected restored metadata %+v, got %+v", wantAll, gotAll)
+	}
+
+	// The pending update should replay identically after restore.
+	restored.Advance(9500)
+	meta, exists := restored.GetMetadata(3)
+	if !exists || !meta.Valid || meta.Comm != "pending" {
+		t.Errorf("Expected pending update to replay after restore, got %+v, %v", meta, exists)
 	}
 }
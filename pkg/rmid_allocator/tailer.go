@@ -0,0 +1,86 @@
+package rmid_allocator
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/unvariance/collector/pkg/perf"
+	"github.com/unvariance/collector/pkg/perf_ebpf"
+)
+
+// walEventSize is the wire size of the ring buffer sample
+// rmid_allocator.bpf.c emits on every successful rmid_alloc/rmid_free call:
+// a 1-byte op code, 3 bytes of padding, a 4-byte RMID, and an 8-byte
+// timestamp.
+const walEventSize = 1 + 3 + 4 + 8
+
+// decodeWALEvent parses one ring buffer sample into a WALRecord.
+func decodeWALEvent(raw []byte) (WALRecord, error) {
+	if len(raw) < walEventSize {
+		return WALRecord{}, fmt.Errorf("rmid_allocator: sample too short: got %d bytes, want at least %d", len(raw), walEventSize)
+	}
+	return WALRecord{
+		Op:          raw[0],
+		RMID:        binary.LittleEndian.Uint32(raw[4:8]),
+		TimestampNs: binary.LittleEndian.Uint64(raw[8:16]),
+	}, nil
+}
+
+// Tailer reads rmid_alloc/rmid_free events off the BPF program's ring
+// buffer and appends each one to a WALWriter, so RecoverFromWAL can rebuild
+// allocator state after a crash or restart.
+type Tailer struct {
+	reader perf_ebpf.EventReader
+	writer *WALWriter
+}
+
+// NewTailer creates a Tailer that decodes events from reader and appends
+// them to writer.
+func NewTailer(reader perf_ebpf.EventReader, writer *WALWriter) *Tailer {
+	return &Tailer{reader: reader, writer: writer}
+}
+
+// Run decodes and appends events until reader.Read returns an error (e.g.
+// because the reader was closed), which it then returns to the caller. A
+// sample that fails to decode is skipped rather than treated as fatal, the
+// same way cmd/bpftracer's event loop handles a malformed sample. Run is
+// meant to be driven from its own goroutine.
+func (t *Tailer) Run() error {
+	var rec perf.Record
+	for {
+		if err := t.reader.Read(&rec); err != nil {
+			return err
+		}
+		walRec, err := decodeWALEvent(rec.RawSample)
+		if err != nil {
+			continue
+		}
+		if err := t.writer.AppendRecord(walRec); err != nil {
+			return fmt.Errorf("appending WAL record: %w", err)
+		}
+	}
+}
+
+// StartRecoveryAndTailing replays dir's existing WAL against target via
+// RecoverFromWAL, then starts a Tailer appending new events from reader to a
+// fresh WALWriter in a background goroutine. It's meant to be called once at
+// process startup, after target (the BPF allocator's maps) has been loaded
+// but before any RMIDs are handed out, so no alloc/free can race recovery.
+func StartRecoveryAndTailing(dir string, target RecoveryTarget, reader perf_ebpf.EventReader, maxSegmentBytes int64) (*WALWriter, *RecoveryResult, error) {
+	result, err := RecoverFromWAL(dir, target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("recovering from WAL: %w", err)
+	}
+
+	writer, err := NewWALWriter(dir, maxSegmentBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening WAL writer: %w", err)
+	}
+
+	tailer := NewTailer(reader, writer)
+	go func() {
+		_ = tailer.Run()
+	}()
+
+	return writer, result, nil
+}
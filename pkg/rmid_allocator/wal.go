@@ -0,0 +1,499 @@
+package rmid_allocator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WAL op codes, mirroring the ring buffer events rmid_allocator.bpf.c emits
+// to userspace on every successful rmid_alloc/rmid_free call.
+const (
+	WALOpAlloc uint8 = 1
+	WALOpFree  uint8 = 2
+)
+
+// WALRecord is one allocate/free event as read off the BPF ring buffer and
+// persisted to the write-ahead log.
+type WALRecord struct {
+	Op          uint8
+	RMID        uint32
+	TimestampNs uint64
+}
+
+// walFrameSize is the on-disk size of one WALRecord: Op (padded to 4 bytes
+// for alignment), RMID (4), TimestampNs (8), and a trailing CRC-32C computed
+// over the preceding 16 bytes (4).
+const walFrameSize = 4 + 4 + 8 + 4
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	walSegmentPrefix       = "segment-"
+	walSegmentSuffix       = ".wal"
+	defaultMaxSegmentBytes = 16 << 20
+)
+
+// ErrWALTailReached is returned by WALReader.Next when a frame is truncated
+// or fails its CRC check. Either looks like a write that was interrupted by
+// a crash, so it's treated the same way: as the end of the usable log,
+// rather than a hard error that would abort recovery partway through an
+// otherwise-intact segment sequence.
+var ErrWALTailReached = errors.New("rmid_allocator: WAL tail reached (truncated or corrupt frame)")
+
+func walSegmentPath(dir string, index uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%016x%s", walSegmentPrefix, index, walSegmentSuffix))
+}
+
+// listWALSegments returns the indices of every WAL segment file in dir,
+// sorted ascending.
+func listWALSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing WAL directory: %w", err)
+	}
+
+	var indices []uint64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		hexPart := strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix)
+		index, err := strconv.ParseUint(hexPart, 16, 64)
+		if err != nil {
+			continue
+		}
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices, nil
+}
+
+func encodeWALFrame(rec WALRecord) [walFrameSize]byte {
+	var buf [walFrameSize]byte
+	buf[0] = rec.Op
+	binary.LittleEndian.PutUint32(buf[4:8], rec.RMID)
+	binary.LittleEndian.PutUint64(buf[8:16], rec.TimestampNs)
+	binary.LittleEndian.PutUint32(buf[16:20], crc32.Checksum(buf[:16], walCRCTable))
+	return buf
+}
+
+func decodeWALFrame(buf []byte) (WALRecord, error) {
+	if crc32.Checksum(buf[:16], walCRCTable) != binary.LittleEndian.Uint32(buf[16:20]) {
+		return WALRecord{}, ErrWALTailReached
+	}
+	return WALRecord{
+		Op:          buf[0],
+		RMID:        binary.LittleEndian.Uint32(buf[4:8]),
+		TimestampNs: binary.LittleEndian.Uint64(buf[8:16]),
+	}, nil
+}
+
+// WALWriter appends WALRecords to a rotating sequence of segment files under
+// Dir, named segment-<index>.wal with index as 16 hex digits. It is meant to
+// be driven by a goroutine tailing the BPF program's alloc/free ring buffer,
+// one AppendRecord call per event, so that allocator state can be rebuilt by
+// RecoverFromWAL after a restart.
+type WALWriter struct {
+	dir             string
+	maxSegmentBytes int64
+
+	file        *os.File
+	buf         *bufio.Writer
+	segmentSize int64
+	nextIndex   uint64
+}
+
+// NewWALWriter creates dir if needed and opens a new segment to append to.
+// maxSegmentBytes rotates to a new segment once the current one would exceed
+// it; 0 uses defaultMaxSegmentBytes (16 MiB).
+func NewWALWriter(dir string, maxSegmentBytes int64) (*WALWriter, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("rmid_allocator: WAL directory must not be empty")
+	}
+	if maxSegmentBytes <= 0 {
+		maxSegmentBytes = defaultMaxSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating WAL directory: %w", err)
+	}
+
+	existing, err := listWALSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WALWriter{dir: dir, maxSegmentBytes: maxSegmentBytes}
+	if len(existing) > 0 {
+		w.nextIndex = existing[len(existing)-1] + 1
+	}
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WALWriter) openSegment() error {
+	path := walSegmentPath(w.dir, w.nextIndex)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %s: %w", path, err)
+	}
+	w.nextIndex++
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.segmentSize = 0
+	return nil
+}
+
+// Rotate closes the current segment and begins a new one, regardless of size.
+func (w *WALWriter) Rotate() error {
+	if err := w.flushAndSync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("closing WAL segment: %w", err)
+	}
+	return w.openSegment()
+}
+
+// AppendRecord writes rec to the current segment, rotating first if that
+// would exceed maxSegmentBytes, and fsyncs before returning: callers rely on
+// an AppendRecord that returned nil surviving a crash, since that's the
+// entire point of the journal.
+func (w *WALWriter) AppendRecord(rec WALRecord) error {
+	if w.segmentSize > 0 && w.segmentSize+walFrameSize > w.maxSegmentBytes {
+		if err := w.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	frame := encodeWALFrame(rec)
+	if _, err := w.buf.Write(frame[:]); err != nil {
+		return fmt.Errorf("writing WAL frame: %w", err)
+	}
+	w.segmentSize += walFrameSize
+
+	return w.flushAndSync()
+}
+
+func (w *WALWriter) flushAndSync() error {
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("flushing WAL segment: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("syncing WAL segment: %w", err)
+	}
+	return nil
+}
+
+// Close flushes, syncs, and closes the current segment.
+func (w *WALWriter) Close() error {
+	if err := w.flushAndSync(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+// WALReader iterates the WALRecords written by a WALWriter, in segment and
+// append order.
+type WALReader struct {
+	dir      string
+	segments []uint64
+	segIdx   int
+
+	file *os.File
+	r    *bufio.Reader
+}
+
+// NewWALReader opens dir for reading, starting from its earliest segment.
+func NewWALReader(dir string) (*WALReader, error) {
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	reader := &WALReader{dir: dir, segments: segments}
+	if err := reader.openNextSegment(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (r *WALReader) openNextSegment() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	if r.segIdx >= len(r.segments) {
+		return io.EOF
+	}
+	path := walSegmentPath(r.dir, r.segments[r.segIdx])
+	r.segIdx++
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment %s: %w", path, err)
+	}
+	r.file = f
+	r.r = bufio.NewReader(f)
+	return nil
+}
+
+// Next returns the next WALRecord in the log. It returns io.EOF once every
+// segment has been fully and cleanly consumed, or ErrWALTailReached if the
+// current segment ends in a truncated or corrupt frame - the signature of a
+// writer that crashed mid-append. Either way, the caller should simply stop
+// reading; everything returned before the error is valid.
+//
+// A segment ending exactly on a frame boundary is always a legitimate place
+// to move on to the next segment (that's what a normal rotation looks like);
+// only a partial frame at the very end of the file - a write interrupted
+// mid-append - is treated as the tail of the whole log.
+func (r *WALReader) Next() (WALRecord, error) {
+	if r.file == nil {
+		return WALRecord{}, io.EOF
+	}
+
+	var raw [walFrameSize]byte
+	if _, err := io.ReadFull(r.r, raw[:]); err != nil {
+		if err == io.EOF {
+			if openErr := r.openNextSegment(); openErr != nil {
+				return WALRecord{}, openErr
+			}
+			return r.Next()
+		}
+		return WALRecord{}, ErrWALTailReached
+	}
+
+	return decodeWALFrame(raw[:])
+}
+
+// Close closes the currently open segment, if any.
+func (r *WALReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// readWALSegment reads every well-formed frame from a single segment file,
+// stopping (without error) at the first truncated or corrupt one.
+func readWALSegment(path string) ([]WALRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening WAL segment %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var records []WALRecord
+	for {
+		var raw [walFrameSize]byte
+		if _, err := io.ReadFull(r, raw[:]); err != nil {
+			break
+		}
+		rec, err := decodeWALFrame(raw[:])
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// RecoveryTarget is whatever backs the live allocator state that
+// RecoverFromWAL replays survivors against: in production, the BPF
+// allocator's maps via generated bindings; in tests, a fake or the
+// RmidTest* wrapper functions.
+type RecoveryTarget interface {
+	// RmidAllocSpecific re-allocates exactly rmid, the way it was allocated
+	// before the crash - unlike a normal allocation, which picks whatever
+	// RMID is free, recovery must reproduce the exact pre-crash assignment.
+	RmidAllocSpecific(rmid uint32, timestampNs uint64) error
+	// RmidFree frees rmid, re-seeding its free time to timestampNs (the
+	// original free time recorded before the crash) rather than wall-clock
+	// now, so min_free_time_ns is honored exactly as it would have been.
+	RmidFree(rmid uint32, timestampNs uint64) error
+}
+
+// RecoveryResult summarizes what RecoverFromWAL replayed.
+type RecoveryResult struct {
+	// RecordsReplayed is the number of WAL records successfully replayed.
+	RecordsReplayed int
+	// TailTruncated is true if recovery stopped early because of a
+	// truncated or corrupt frame, rather than reaching a clean end of log.
+	TailTruncated bool
+}
+
+// RecoverFromWAL replays every record in dir's WAL segments, in order,
+// against target, rebuilding the allocator state an in-memory BPF map would
+// have held immediately before a crash. It stops at the first torn or
+// corrupt frame rather than failing outright, since that's the expected
+// shape of a writer that crashed mid-append.
+func RecoverFromWAL(dir string, target RecoveryTarget) (*RecoveryResult, error) {
+	r, err := NewWALReader(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := &RecoveryResult{}
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			if errors.Is(err, ErrWALTailReached) {
+				result.TailTruncated = true
+				return result, nil
+			}
+			return result, err
+		}
+
+		switch rec.Op {
+		case WALOpAlloc:
+			if err := target.RmidAllocSpecific(rec.RMID, rec.TimestampNs); err != nil {
+				return result, fmt.Errorf("replaying alloc of RMID %d: %w", rec.RMID, err)
+			}
+		case WALOpFree:
+			if err := target.RmidFree(rec.RMID, rec.TimestampNs); err != nil {
+				return result, fmt.Errorf("replaying free of RMID %d: %w", rec.RMID, err)
+			}
+		default:
+			return result, fmt.Errorf("unknown WAL op %d for RMID %d", rec.Op, rec.RMID)
+		}
+		result.RecordsReplayed++
+	}
+}
+
+// CompactSegments drops WAL segments under dir whose records are no longer
+// needed for recovery: a segment is safe to remove once, for every RMID it
+// mentions, either a later segment has a more recent record for that RMID
+// (so this one is superseded), or this segment's record is that RMID's most
+// recent and is a free that happened more than minFreeTimeNs before nowNs
+// (so the RMID could not still be reachable within min_free_time_ns of now).
+// The most recent segment is never compacted, since a WALWriter may still be
+// appending to it, and it is not read either: only records in the older
+// segments count when deciding which record is an RMID's most recent. It
+// returns the number of segments removed.
+func CompactSegments(dir string, nowNs, minFreeTimeNs uint64) (int, error) {
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(segments) <= 1 {
+		return 0, nil
+	}
+	segments = segments[:len(segments)-1]
+
+	type lastRecord struct {
+		segPos int
+		rec    WALRecord
+	}
+
+	recordsBySeg := make([][]WALRecord, len(segments))
+	lastForRMID := make(map[uint32]lastRecord)
+
+	for i, index := range segments {
+		recs, err := readWALSegment(walSegmentPath(dir, index))
+		if err != nil {
+			return 0, err
+		}
+		recordsBySeg[i] = recs
+		for _, rec := range recs {
+			lastForRMID[rec.RMID] = lastRecord{segPos: i, rec: rec}
+		}
+	}
+
+	removed := 0
+	for i, index := range segments {
+		safe := true
+		for _, rec := range recordsBySeg[i] {
+			last := lastForRMID[rec.RMID]
+			if last.segPos != i {
+				// A later segment holds the authoritative record for this RMID.
+				continue
+			}
+			if last.rec.Op != WALOpFree || nowNs-last.rec.TimestampNs <= minFreeTimeNs {
+				safe = false
+				break
+			}
+		}
+		if !safe {
+			continue
+		}
+		if err := os.Remove(walSegmentPath(dir, index)); err != nil {
+			return removed, fmt.Errorf("removing compacted WAL segment: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Compactor periodically runs CompactSegments in the background so the WAL
+// directory doesn't grow without bound once freed RMIDs age past
+// minFreeTimeNs. It mirrors checker.MonGroupManager's eviction loop.
+type Compactor struct {
+	dir           string
+	minFreeTimeNs uint64
+	nowFn         func() uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCompactor starts a background loop that calls CompactSegments against
+// dir every interval, using nowFn for the current time (so tests can inject
+// a fake clock instead of wall-clock time.Now). Call Close to stop it.
+func NewCompactor(dir string, minFreeTimeNs uint64, interval time.Duration, nowFn func() uint64) *Compactor {
+	c := &Compactor{
+		dir:           dir,
+		minFreeTimeNs: minFreeTimeNs,
+		nowFn:         nowFn,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go c.loop(interval)
+	return c
+}
+
+func (c *Compactor) loop(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			// Best-effort: a failed compaction pass just means the WAL stays
+			// a bit larger until the next tick, not a correctness issue.
+			_, _ = CompactSegments(c.dir, c.nowFn(), c.minFreeTimeNs)
+		}
+	}
+}
+
+// Close stops the background compaction loop.
+func (c *Compactor) Close() error {
+	close(c.stop)
+	<-c.done
+	return nil
+}
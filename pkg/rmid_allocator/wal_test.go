@@ -0,0 +1,438 @@
+package rmid_allocator
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWALWriteReadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWALWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+
+	want := []WALRecord{
+		{Op: WALOpAlloc, RMID: 1, TimestampNs: 1000},
+		{Op: WALOpAlloc, RMID: 2, TimestampNs: 2000},
+		{Op: WALOpFree, RMID: 1, TimestampNs: 3000},
+		{Op: WALOpAlloc, RMID: 1, TimestampNs: 4000},
+	}
+	for _, rec := range want {
+		if err := w.AppendRecord(rec); err != nil {
+			t.Fatalf("AppendRecord: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := NewWALReader(dir)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	for i, wantRec := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() at record %d: %v", i, err)
+		}
+		if got != wantRec {
+			t.Errorf("record %d: expected %+v, got %+v", i, wantRec, got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after last record, got %v", err)
+	}
+}
+
+func TestWALWriterRotatesSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	// walFrameSize is 20 bytes; a 50-byte budget rotates every 2 records.
+	w, err := NewWALWriter(dir, 50)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := w.AppendRecord(WALRecord{Op: WALOpAlloc, RMID: uint32(i + 1), TimestampNs: uint64(i)}); err != nil {
+			t.Fatalf("AppendRecord: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		t.Fatalf("listWALSegments: %v", err)
+	}
+	if len(segments) < 3 {
+		t.Fatalf("expected at least 3 segments from rotation, got %d", len(segments))
+	}
+
+	r, err := NewWALReader(dir)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	count := 0
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next(): %v", err)
+		}
+		count++
+	}
+	if count != 6 {
+		t.Errorf("expected 6 records across rotated segments, got %d", count)
+	}
+}
+
+func TestWALReaderStopsAtTruncatedFrame(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWALWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.AppendRecord(WALRecord{Op: WALOpAlloc, RMID: 1, TimestampNs: 1}); err != nil {
+		t.Fatalf("AppendRecord: %v", err)
+	}
+	if err := w.AppendRecord(WALRecord{Op: WALOpAlloc, RMID: 2, TimestampNs: 2}); err != nil {
+		t.Fatalf("AppendRecord: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	segments, err := listWALSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected a single segment, got %v (err %v)", segments, err)
+	}
+	path := walSegmentPath(dir, segments[0])
+	if err := os.Truncate(path, walFrameSize+walFrameSize/2); err != nil {
+		t.Fatalf("truncating segment: %v", err)
+	}
+
+	r, err := NewWALReader(dir)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+
+	first, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next() for first record: %v", err)
+	}
+	if first.RMID != 1 {
+		t.Errorf("expected first record's RMID 1, got %d", first.RMID)
+	}
+
+	if _, err := r.Next(); !errors.Is(err, ErrWALTailReached) {
+		t.Errorf("expected ErrWALTailReached at the truncated frame, got %v", err)
+	}
+}
+
+// fakeTarget is a minimal in-memory RecoveryTarget used to verify
+// RecoverFromWAL's replay without depending on the BPF program.
+type fakeTarget struct {
+	allocated map[uint32]uint64
+	freedAt   map[uint32]uint64
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{allocated: make(map[uint32]uint64), freedAt: make(map[uint32]uint64)}
+}
+
+func (f *fakeTarget) RmidAllocSpecific(rmid uint32, timestampNs uint64) error {
+	f.allocated[rmid] = timestampNs
+	delete(f.freedAt, rmid)
+	return nil
+}
+
+func (f *fakeTarget) RmidFree(rmid uint32, timestampNs uint64) error {
+	delete(f.allocated, rmid)
+	f.freedAt[rmid] = timestampNs
+	return nil
+}
+
+// replay applies records[:n] to a fresh fakeTarget, mirroring what
+// RecoverFromWAL does internally, so tests can compute an expected state.
+func replay(records []WALRecord, n int) *fakeTarget {
+	target := newFakeTarget()
+	for _, rec := range records[:n] {
+		switch rec.Op {
+		case WALOpAlloc:
+			target.RmidAllocSpecific(rec.RMID, rec.TimestampNs)
+		case WALOpFree:
+			target.RmidFree(rec.RMID, rec.TimestampNs)
+		}
+	}
+	return target
+}
+
+func TestRecoverFromWALReplaysCleanLog(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWALWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	records := []WALRecord{
+		{Op: WALOpAlloc, RMID: 1, TimestampNs: 100},
+		{Op: WALOpAlloc, RMID: 2, TimestampNs: 200},
+		{Op: WALOpFree, RMID: 1, TimestampNs: 300},
+		{Op: WALOpAlloc, RMID: 3, TimestampNs: 400},
+	}
+	for _, rec := range records {
+		if err := w.AppendRecord(rec); err != nil {
+			t.Fatalf("AppendRecord: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	target := newFakeTarget()
+	result, err := RecoverFromWAL(dir, target)
+	if err != nil {
+		t.Fatalf("RecoverFromWAL: %v", err)
+	}
+	if result.TailTruncated {
+		t.Error("expected a clean log to report TailTruncated = false")
+	}
+	if result.RecordsReplayed != len(records) {
+		t.Errorf("expected %d records replayed, got %d", len(records), result.RecordsReplayed)
+	}
+
+	want := replay(records, len(records))
+	if len(target.allocated) != len(want.allocated) {
+		t.Fatalf("allocated set mismatch: got %v, want %v", target.allocated, want.allocated)
+	}
+	for rmid, ts := range want.allocated {
+		if target.allocated[rmid] != ts {
+			t.Errorf("RMID %d: expected alloc timestamp %d, got %d", rmid, ts, target.allocated[rmid])
+		}
+	}
+}
+
+// TestRecoverFromWALRecoversPrefixUnderCorruption injects random truncations
+// and bit-flips into a WAL and verifies that whatever RecoverFromWAL manages
+// to replay is always an exact prefix of the pre-crash record sequence -
+// never a gap, never a record past the corruption point.
+func TestRecoverFromWALRecoversPrefixUnderCorruption(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	for trial := 0; trial < 200; trial++ {
+		dir := t.TempDir()
+
+		n := 5 + rng.Intn(20)
+		records := make([]WALRecord, n)
+		nextRMID := uint32(1)
+		live := map[uint32]bool{}
+		for i := range records {
+			ts := uint64(1000 * (i + 1))
+			if len(live) > 0 && rng.Intn(2) == 0 {
+				var rmid uint32
+				for r := range live {
+					rmid = r
+					break
+				}
+				records[i] = WALRecord{Op: WALOpFree, RMID: rmid, TimestampNs: ts}
+				delete(live, rmid)
+			} else {
+				rmid := nextRMID
+				nextRMID++
+				records[i] = WALRecord{Op: WALOpAlloc, RMID: rmid, TimestampNs: ts}
+				live[rmid] = true
+			}
+		}
+
+		// Rotate frequently so corruption can land in any of several segments.
+		w, err := NewWALWriter(dir, 3*walFrameSize)
+		if err != nil {
+			t.Fatalf("trial %d: NewWALWriter: %v", trial, err)
+		}
+		for _, rec := range records {
+			if err := w.AppendRecord(rec); err != nil {
+				t.Fatalf("trial %d: AppendRecord: %v", trial, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("trial %d: Close: %v", trial, err)
+		}
+
+		corruptRandomByte(t, dir, rng)
+
+		target := newFakeTarget()
+		result, err := RecoverFromWAL(dir, target)
+		if err != nil {
+			t.Fatalf("trial %d: RecoverFromWAL: %v", trial, err)
+		}
+		if result.RecordsReplayed > n {
+			t.Fatalf("trial %d: replayed %d records but only %d were written", trial, result.RecordsReplayed, n)
+		}
+
+		want := replay(records, result.RecordsReplayed)
+		if len(target.allocated) != len(want.allocated) {
+			t.Fatalf("trial %d: allocated set mismatch: got %v, want %v (replayed %d/%d)",
+				trial, target.allocated, want.allocated, result.RecordsReplayed, n)
+		}
+		for rmid, ts := range want.allocated {
+			if target.allocated[rmid] != ts {
+				t.Errorf("trial %d: RMID %d: expected alloc timestamp %d, got %d", trial, rmid, ts, target.allocated[rmid])
+			}
+		}
+	}
+}
+
+// corruptRandomByte simulates a crash by corrupting one of the WAL segments
+// in dir. A bit-flip can land in any segment - bit rot on disk doesn't care
+// whether a segment has already been rotated away from - and is always
+// caught by its frame's CRC. A truncation, on the other hand, only ever
+// happens to the segment that was still open for writes when the crash hit:
+// earlier segments are already fully flushed and synced by Rotate, so they
+// can't be torn by a process crash, only by flipping bits already on disk.
+func corruptRandomByte(t *testing.T, dir string, rng *rand.Rand) {
+	t.Helper()
+
+	segments, err := listWALSegments(dir)
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("listWALSegments: %v (segments=%v)", err, segments)
+	}
+
+	truncate := rng.Intn(2) == 0
+	var path string
+	if truncate {
+		path = walSegmentPath(dir, segments[len(segments)-1])
+	} else {
+		path = walSegmentPath(dir, segments[rng.Intn(len(segments))])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading segment %s: %v", path, err)
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	offset := rng.Intn(len(data))
+	if truncate {
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			t.Fatalf("truncating segment: %v", err)
+		}
+		return
+	}
+
+	data[offset] ^= 1 << uint(rng.Intn(8))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("rewriting corrupted segment: %v", err)
+	}
+}
+
+func TestCompactSegmentsDropsOnlySupersededOrAgedOutSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWALWriter(dir, 2*walFrameSize)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	// Segment 0: RMID 1 alloc+free, aged out.
+	mustAppend(t, w, WALRecord{Op: WALOpAlloc, RMID: 1, TimestampNs: 1000})
+	mustAppend(t, w, WALRecord{Op: WALOpFree, RMID: 1, TimestampNs: 2000})
+	// Segment 1: RMID 2 alloc, still live (never freed).
+	mustAppend(t, w, WALRecord{Op: WALOpAlloc, RMID: 2, TimestampNs: 3000})
+	mustAppend(t, w, WALRecord{Op: WALOpAlloc, RMID: 3, TimestampNs: 4000})
+	// Segment 2: RMID 3 freed recently (not aged out yet) - current segment,
+	// never compacted regardless.
+	mustAppend(t, w, WALRecord{Op: WALOpFree, RMID: 3, TimestampNs: 5000})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	before, err := listWALSegments(dir)
+	if err != nil || len(before) != 3 {
+		t.Fatalf("expected 3 segments before compaction, got %v (err %v)", before, err)
+	}
+
+	const minFreeTimeNs = 10_000
+	removed, err := CompactSegments(dir, 15_000, minFreeTimeNs)
+	if err != nil {
+		t.Fatalf("CompactSegments: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected exactly 1 segment removed (RMID 1's, aged out), got %d", removed)
+	}
+
+	after, err := listWALSegments(dir)
+	if err != nil {
+		t.Fatalf("listWALSegments: %v", err)
+	}
+	if len(after) != len(before)-removed {
+		t.Errorf("expected %d segments remaining, got %d", len(before)-removed, len(after))
+	}
+	// Segment 0 (index 0) should be gone; the rest remain.
+	if _, err := os.Stat(walSegmentPath(dir, before[0])); !os.IsNotExist(err) {
+		t.Errorf("expected segment %d to be removed", before[0])
+	}
+	for _, idx := range before[1:] {
+		if _, err := os.Stat(walSegmentPath(dir, idx)); err != nil {
+			t.Errorf("expected segment %d to survive compaction: %v", idx, err)
+		}
+	}
+}
+
+func mustAppend(t *testing.T, w *WALWriter, rec WALRecord) {
+	t.Helper()
+	if err := w.AppendRecord(rec); err != nil {
+		t.Fatalf("AppendRecord(%+v): %v", rec, err)
+	}
+}
+
+func TestCompactorBackgroundLoop(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWALWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	mustAppend(t, w, WALRecord{Op: WALOpAlloc, RMID: 1, TimestampNs: 1000})
+	mustAppend(t, w, WALRecord{Op: WALOpFree, RMID: 1, TimestampNs: 2000})
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	mustAppend(t, w, WALRecord{Op: WALOpAlloc, RMID: 2, TimestampNs: 3000})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	now := uint64(100_000)
+	compactor := NewCompactor(dir, 1000, 5*time.Millisecond, func() uint64 { return now })
+	defer compactor.Close()
+
+	deadline := 200 * time.Millisecond
+	interval := 5 * time.Millisecond
+	for elapsed := time.Duration(0); elapsed < deadline; elapsed += interval {
+		segments, err := listWALSegments(dir)
+		if err != nil {
+			t.Fatalf("listWALSegments: %v", err)
+		}
+		if len(segments) == 1 {
+			return
+		}
+		time.Sleep(interval)
+	}
+	t.Fatal("expected the background compactor to remove the aged-out first segment")
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package runtimetune
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// These are variables rather than constants so tests can point them at a fixture
+// directory instead of the real /sys/fs/cgroup.
+var (
+	cgroupV2CPUMax          = "/sys/fs/cgroup/cpu.max"
+	cgroupV2MemoryMax       = "/sys/fs/cgroup/memory.max"
+	cgroupV2CpusetEffective = "/sys/fs/cgroup/cpuset.cpus.effective"
+
+	cgroupV1CFSQuota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CFSPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+	cgroupV1MemLimit  = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+)
+
+// memoryLimitFraction is the fraction of the cgroup memory ceiling passed to
+// debug.SetMemoryLimit, leaving headroom for non-Go memory and GC overshoot.
+const memoryLimitFraction = 0.9
+
+// ReadCPUQuota returns the effective CPU quota, preferring cgroup v2's unified
+// cpu.max and falling back to the v1 cpu.cfs_quota_us/cpu.cfs_period_us pair.
+func ReadCPUQuota() (CPUQuota, bool) {
+	if data, err := os.ReadFile(cgroupV2CPUMax); err == nil {
+		fields := strings.Fields(strings.TrimSpace(string(data)))
+		if len(fields) == 2 {
+			period, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return CPUQuota{}, false
+			}
+			if fields[0] == "max" {
+				return CPUQuota{PeriodUs: period, Unlimited: true}, true
+			}
+			quota, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return CPUQuota{}, false
+			}
+			return CPUQuota{QuotaUs: quota, PeriodUs: period}, true
+		}
+	}
+
+	quota, qErr := readInt64(cgroupV1CFSQuota)
+	period, pErr := readInt64(cgroupV1CFSPeriod)
+	if qErr != nil || pErr != nil {
+		return CPUQuota{}, false
+	}
+	if quota < 0 {
+		return CPUQuota{PeriodUs: period, Unlimited: true}, true
+	}
+	return CPUQuota{QuotaUs: quota, PeriodUs: period}, true
+}
+
+// ReadMemoryLimit returns the cgroup memory ceiling in bytes. The second return
+// value is false if no limit is set (cgroup v2 "max") or the files can't be read.
+func ReadMemoryLimit() (uint64, bool) {
+	if data, err := os.ReadFile(cgroupV2MemoryMax); err == nil {
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0, false
+		}
+		limit, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return limit, true
+	}
+
+	limit, err := readUint64(cgroupV1MemLimit)
+	if err != nil {
+		return 0, false
+	}
+	// cgroup v1 reports an enormous sentinel value (close to the max representable
+	// counter) when no limit is configured.
+	if limit > (1 << 62) {
+		return 0, false
+	}
+	return limit, true
+}
+
+// EffectiveCPUs returns the CPUs this process is actually allowed to run on: the
+// intersection of sched_getaffinity and the cpuset controller's cpuset.cpus.effective,
+// falling back to sched_getaffinity alone if the cpuset file isn't present.
+func EffectiveCPUs() ([]int, error) {
+	var affinity unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &affinity); err != nil {
+		return nil, fmt.Errorf("sched_getaffinity: %w", err)
+	}
+
+	allowed := make(map[int]bool)
+	for cpu := 0; cpu < len(affinity)*64; cpu++ {
+		if affinity.IsSet(cpu) {
+			allowed[cpu] = true
+		}
+	}
+
+	if cpuset, err := parseCPUList(cgroupV2CpusetEffective); err == nil {
+		for cpu := range allowed {
+			if !cpuset[cpu] {
+				delete(allowed, cpu)
+			}
+		}
+	}
+
+	cpus := make([]int, 0, len(allowed))
+	for cpu := range allowed {
+		cpus = append(cpus, cpu)
+	}
+	return cpus, nil
+}
+
+// parseCPUList parses a Linux CPU list file (e.g. "0-3,7") into a set of CPU ids.
+func parseCPUList(path string) (map[int]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]bool)
+	for _, part := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			start, err := strconv.Atoi(lo)
+			if err != nil {
+				return nil, err
+			}
+			end, err := strconv.Atoi(hi)
+			if err != nil {
+				return nil, err
+			}
+			for cpu := start; cpu <= end; cpu++ {
+				result[cpu] = true
+			}
+		} else {
+			cpu, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, err
+			}
+			result[cpu] = true
+		}
+	}
+	return result, nil
+}
+
+func readInt64(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readUint64(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Tune applies GOMAXPROCS and debug.SetMemoryLimit based on the cgroup's CPU quota
+// and memory ceiling. It leaves GOMAXPROCS untouched if the user already set it via
+// the GOMAXPROCS environment variable, and skips the memory limit if AUTOMEMLIMIT=off.
+func Tune() {
+	if os.Getenv("GOMAXPROCS") == "" {
+		if quota, ok := ReadCPUQuota(); ok && !quota.Unlimited {
+			if cores := quota.Cores(); cores > 0 {
+				runtime.GOMAXPROCS(cores)
+			}
+		}
+	}
+
+	if os.Getenv("AUTOMEMLIMIT") != "off" {
+		if limit, ok := ReadMemoryLimit(); ok {
+			debug.SetMemoryLimit(int64(float64(limit) * memoryLimitFraction))
+		}
+	}
+}
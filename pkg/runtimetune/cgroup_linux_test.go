@@ -0,0 +1,157 @@
+//go:build linux
+
+package runtimetune
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func withPaths(t *testing.T, set func(dir string)) {
+	t.Helper()
+	dir := t.TempDir()
+	origV2CPU, origV2Mem, origV2Cpuset := cgroupV2CPUMax, cgroupV2MemoryMax, cgroupV2CpusetEffective
+	origV1Quota, origV1Period, origV1Mem := cgroupV1CFSQuota, cgroupV1CFSPeriod, cgroupV1MemLimit
+	cgroupV2CPUMax = filepath.Join(dir, "does-not-exist-cpu.max")
+	cgroupV2MemoryMax = filepath.Join(dir, "does-not-exist-memory.max")
+	cgroupV2CpusetEffective = filepath.Join(dir, "does-not-exist-cpuset.cpus.effective")
+	cgroupV1CFSQuota = filepath.Join(dir, "does-not-exist-cfs_quota_us")
+	cgroupV1CFSPeriod = filepath.Join(dir, "does-not-exist-cfs_period_us")
+	cgroupV1MemLimit = filepath.Join(dir, "does-not-exist-memory.limit_in_bytes")
+	t.Cleanup(func() {
+		cgroupV2CPUMax, cgroupV2MemoryMax, cgroupV2CpusetEffective = origV2CPU, origV2Mem, origV2Cpuset
+		cgroupV1CFSQuota, cgroupV1CFSPeriod, cgroupV1MemLimit = origV1Quota, origV1Period, origV1Mem
+	})
+	set(dir)
+}
+
+func TestReadCPUQuotaV2(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV2CPUMax, "200000 100000\n")
+		quota, ok := ReadCPUQuota()
+		if !ok {
+			t.Fatal("expected ok")
+		}
+		if quota.Unlimited {
+			t.Fatal("expected limited quota")
+		}
+		if quota.QuotaUs != 200000 || quota.PeriodUs != 100000 {
+			t.Fatalf("unexpected quota: %+v", quota)
+		}
+		if cores := quota.Cores(); cores != 2 {
+			t.Fatalf("expected 2 cores, got %d", cores)
+		}
+	})
+}
+
+func TestReadCPUQuotaV2Unlimited(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV2CPUMax, "max 100000\n")
+		quota, ok := ReadCPUQuota()
+		if !ok {
+			t.Fatal("expected ok")
+		}
+		if !quota.Unlimited {
+			t.Fatal("expected unlimited quota")
+		}
+	})
+}
+
+func TestReadCPUQuotaV1Fallback(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV1CFSQuota, "50000\n")
+		writeFile(t, cgroupV1CFSPeriod, "100000\n")
+		quota, ok := ReadCPUQuota()
+		if !ok {
+			t.Fatal("expected ok")
+		}
+		if quota.QuotaUs != 50000 || quota.PeriodUs != 100000 {
+			t.Fatalf("unexpected quota: %+v", quota)
+		}
+		if cores := quota.Cores(); cores != 1 {
+			t.Fatalf("expected 1 core, got %d", cores)
+		}
+	})
+}
+
+func TestReadCPUQuotaV1Unlimited(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV1CFSQuota, "-1\n")
+		writeFile(t, cgroupV1CFSPeriod, "100000\n")
+		quota, ok := ReadCPUQuota()
+		if !ok {
+			t.Fatal("expected ok")
+		}
+		if !quota.Unlimited {
+			t.Fatal("expected unlimited quota")
+		}
+	})
+}
+
+func TestReadMemoryLimitV2(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV2MemoryMax, "1073741824\n")
+		limit, ok := ReadMemoryLimit()
+		if !ok || limit != 1073741824 {
+			t.Fatalf("unexpected limit: %d ok=%v", limit, ok)
+		}
+	})
+}
+
+func TestReadMemoryLimitV2Unlimited(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV2MemoryMax, "max\n")
+		_, ok := ReadMemoryLimit()
+		if ok {
+			t.Fatal("expected unlimited (ok=false)")
+		}
+	})
+}
+
+func TestReadMemoryLimitV1Sentinel(t *testing.T) {
+	withPaths(t, func(dir string) {
+		writeFile(t, cgroupV1MemLimit, "9223372036854771712\n")
+		_, ok := ReadMemoryLimit()
+		if ok {
+			t.Fatal("expected unlimited (ok=false) for v1 sentinel value")
+		}
+	})
+}
+
+func TestParseCPUList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cpus.effective")
+	writeFile(t, path, "0-3,7\n")
+	set, err := parseCPUList(path)
+	if err != nil {
+		t.Fatalf("parseCPUList: %v", err)
+	}
+	for _, cpu := range []int{0, 1, 2, 3, 7} {
+		if !set[cpu] {
+			t.Errorf("expected cpu %d to be set", cpu)
+		}
+	}
+	if set[4] || set[5] || set[6] {
+		t.Errorf("unexpected cpus set: %+v", set)
+	}
+}
+
+func TestEffectiveCPUsFallsBackWithoutCpuset(t *testing.T) {
+	withPaths(t, func(dir string) {
+		cpus, err := EffectiveCPUs()
+		if err != nil {
+			t.Fatalf("EffectiveCPUs: %v", err)
+		}
+		if len(cpus) == 0 {
+			t.Fatal("expected at least one allowed cpu")
+		}
+	})
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package runtimetune
+
+import "runtime"
+
+// ReadCPUQuota is unsupported outside Linux; cgroups don't exist elsewhere.
+func ReadCPUQuota() (CPUQuota, bool) { return CPUQuota{}, false }
+
+// ReadMemoryLimit is unsupported outside Linux; cgroups don't exist elsewhere.
+func ReadMemoryLimit() (uint64, bool) { return 0, false }
+
+// EffectiveCPUs falls back to every CPU Go itself is aware of.
+func EffectiveCPUs() ([]int, error) {
+	cpus := make([]int, runtime.NumCPU())
+	for i := range cpus {
+		cpus[i] = i
+	}
+	return cpus, nil
+}
+
+// Tune is a noop outside Linux, keeping cross-platform builds green.
+func Tune() {}
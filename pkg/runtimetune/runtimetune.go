@@ -0,0 +1,27 @@
+// Package runtimetune adjusts Go runtime and buffer-sizing knobs to the CPU and
+// memory limits of the cgroup the process is running in, so memory-collector doesn't
+// over-allocate per-CPU state or get throttled when run inside a container.
+package runtimetune
+
+// CPUQuota describes the effective CPU allotment derived from the cgroup CPU
+// controller: Quota/Period microseconds of CPU time per scheduling period, or
+// Unlimited if no quota is set.
+type CPUQuota struct {
+	QuotaUs   int64
+	PeriodUs  int64
+	Unlimited bool
+}
+
+// Cores returns the number of CPU cores implied by the quota, rounded up, with a
+// floor of 1. It returns 0 if the quota is unlimited (the caller should fall back
+// to the host/cpuset CPU count).
+func (q CPUQuota) Cores() int {
+	if q.Unlimited || q.PeriodUs <= 0 {
+		return 0
+	}
+	cores := (q.QuotaUs + q.PeriodUs - 1) / q.PeriodUs
+	if cores < 1 {
+		cores = 1
+	}
+	return int(cores)
+}
@@ -2,10 +2,10 @@ package sync_timer
 
 import (
 	"fmt"
-	"runtime"
 	"time"
 
 	"github.com/cilium/ebpf"
+	"github.com/unvariance/collector/pkg/runtimetune"
 	"golang.org/x/sys/unix"
 )
 
@@ -14,6 +14,7 @@ type SyncTimer struct {
 	initProgram *ebpf.Program
 	timerStates *ebpf.Map
 	initStatus  *ebpf.Map
+	cpus        []int
 }
 
 // NewSyncTimer creates a new synchronized timer system
@@ -38,8 +39,14 @@ func (st *SyncTimer) Start() error {
 	}
 	defer guard.Close()
 
-	// Initialize timers on each CPU
-	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+	cpus, err := runtimetune.EffectiveCPUs()
+	if err != nil {
+		return fmt.Errorf("determining effective CPUs: %w", err)
+	}
+	st.cpus = cpus
+
+	// Initialize timers on each CPU actually available to this process.
+	for _, cpu := range st.cpus {
 		err := setCPUAffinity(cpu)
 		if err != nil {
 			return fmt.Errorf("setting CPU affinity for CPU %d: %w", cpu, err)
@@ -61,7 +68,7 @@ func (st *SyncTimer) Start() error {
 			// Check initialization status for all CPUs
 			allInitialized := true
 			initializedCount := 0
-			for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+			for _, cpu := range st.cpus {
 				var initFlag uint8
 				if err := st.initStatus.Lookup(uint32(cpu), &initFlag); err != nil || initFlag == 0 {
 					allInitialized = false
@@ -81,7 +88,13 @@ func (st *SyncTimer) Start() error {
 // Stop cleans up the synchronized timer system
 func (st *SyncTimer) Stop() {
 	// Reset all timer states to stop the timers
-	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+	cpus := st.cpus
+	if cpus == nil {
+		if fallback, err := runtimetune.EffectiveCPUs(); err == nil {
+			cpus = fallback
+		}
+	}
+	for _, cpu := range cpus {
 		key := uint32(cpu)
 		_ = st.timerStates.Delete(key)
 		_ = st.initStatus.Delete(key)